@@ -3,6 +3,7 @@ package board
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/Jesselli/tchess/piece"
 	"github.com/Jesselli/tchess/tui"
@@ -15,96 +16,208 @@ const (
 	CASTLE_BLACK_LONG  uint8 = 0b1000
 )
 
+// Returns the castle right of the player on the king's side if short is true,
+// otherwise on the queen's side
+func CastleRight(color piece.Color, short bool) uint8 {
+	return 1 << castleIndex(color, short)
+}
+
+// Index of the right in CastleRights and CastleRookFiles
+func castleIndex(color piece.Color, short bool) int {
+	idx := 0
+	if color == piece.BLACK {
+		idx += 2
+	}
+	if !short {
+		idx++
+	}
+	return idx
+}
+
+// Pieces a pawn can promote to
+var PromotionTypes = []piece.Type{piece.QUEEN, piece.ROOK, piece.BISHOP, piece.KNIGHT}
+
+type Highlight int
+
+const (
+	HIGHLIGHT_NONE     Highlight = 0
+	HIGHLIGHT_TARGET   Highlight = 1 // Legal destination of the selected piece
+	HIGHLIGHT_SELECTED Highlight = 2
+	HIGHLIGHT_CURSOR   Highlight = 3
+)
+
 type Board struct {
-	Pieces         [64]piece.Piece
-	CastleRights   uint8
-	CapturedPieces []piece.Piece
-	HiglightSq     int
+	Pieces       [64]piece.Piece
+	CastleRights uint8
+	// Files of the rooks the kings castle with, indexed like the bits of
+	// CastleRights. Standard chess has them in the corners, Chess960 anywhere
+	// on either side of the king.
+	CastleRookFiles [4]byte
+	CapturedPieces  []piece.Piece
+	LastMoveSrcSq   int // -1 before the first move
+	LastMoveTrgSq   int
+	CheckSq         int // Square of a king in check, -1 if there is none
+	Highlights      [64]Highlight
+	Variant         Variant // Rules of the game, nil for standard chess
+	Checks          [3]int  // Checks given by each player, indexed by color
+	// Pieces each player holds to drop in Crazyhouse, counted by color and type
+	Pockets [3][piece.KING + 1]int
+	// Squares of pieces that were promoted. Taken, they go into the pocket as pawns.
+	Promoted [64]bool
 }
 
-func (b Board) Display(out io.Writer, rotated bool) {
-	tui.MoveCursorTo(0, 0)
+// Width of the rank numbers drawn to the left of the squares
+const rankLabelWidth = 3
 
-	for i := 0; i < 64; i++ {
-		var p piece.Piece
-		if rotated {
-			p = b.Pieces[63-i]
+// Returns the square drawn by Display at the given 1-based screen position
+func SqAtScreenPos(l tui.Layout, row, col int, rotated bool) (int, bool) {
+	row -= l.Board.Row
+	col -= l.Board.Col + rankLabelWidth
+	if row < 0 || col < 0 {
+		return -1, false
+	}
+
+	rankIdx := row / l.SquareHeight
+	fileIdx := col / l.SquareWidth
+	if rankIdx >= 8 || fileIdx >= 8 {
+		return -1, false
+	}
+
+	sqNum := rankIdx*8 + fileIdx
+	if rotated {
+		sqNum = 63 - sqNum
+	}
+	return sqNum, true
+}
+
+func (b Board) Display(out io.Writer, rotated bool, l tui.Layout) {
+	theme := tui.ActiveTheme
+	sqW := l.SquareWidth
+	sqH := l.SquareHeight
+	pieceLine := sqH / 2
+	padLeft := strings.Repeat(" ", (sqW-1)/2)
+	padRight := strings.Repeat(" ", sqW-1-(sqW-1)/2)
+	blank := strings.Repeat(" ", sqW)
+
+	for i := 0; i < 8*sqH; i++ {
+		rankIdx := i / sqH
+		tui.MoveCursorTo(l.Board.Row+i, l.Board.Col)
+		if i%sqH != pieceLine {
+			fmt.Fprint(out, "   ")
+		} else if rotated {
+			fmt.Fprintf(out, "%d  ", 1+rankIdx)
 		} else {
-			p = b.Pieces[i]
+			fmt.Fprintf(out, "%d  ", 8-rankIdx)
 		}
 
-		if i%8 == 0 && rotated {
-			fmt.Fprintln(out)
-			fmt.Fprintf(out, "%d  ", 1+i/8)
-		} else if i%8 == 0 {
-			fmt.Fprintln(out)
-			fmt.Fprintf(out, "%d  ", 8-i/8)
-		}
+		for fileIdx := 0; fileIdx < 8; fileIdx++ {
+			sqNum := rankIdx*8 + fileIdx
+			if rotated {
+				sqNum = 63 - sqNum
+			}
+			p := b.Pieces[sqNum]
+			pieceRune := p.Glyph(theme.Pieces)
 
-		isWhiteSq := (i+i/8)%2 == 0
-		isHighlight := i == b.HiglightSq
-		if rotated {
-			isHighlight = 63-i == b.HiglightSq
-		}
-		pieceRune := piece.PieceRunesFilled[p.Type]
-		if isWhiteSq {
-			// TODO: Use coloring from tui package
 			if p.Color == piece.BLACK {
-				fmt.Fprintf(out, "\u001b[38;5;16m")
+				fmt.Fprint(out, tui.FgCode(theme.BlackPiece))
 			} else {
-				fmt.Fprintf(out, "\u001b[38;5;231m")
+				fmt.Fprint(out, tui.FgCode(theme.WhitePiece))
 			}
 
-			if isHighlight {
-				fmt.Fprintf(out, "\u001b[48;5;81m")
-			} else {
-				fmt.Fprintf(out, "\u001b[48;5;74m")
-			}
-		} else {
-			if p.Color == piece.BLACK {
-				fmt.Fprintf(out, "\u001b[38;5;16m")
-			} else {
-				fmt.Fprintf(out, "\u001b[38;5;231m")
+			switch {
+			case b.Highlights[sqNum] == HIGHLIGHT_TARGET:
+				fmt.Fprint(out, tui.BgCode(theme.Target))
+			case b.Highlights[sqNum] == HIGHLIGHT_SELECTED:
+				fmt.Fprint(out, tui.BgCode(theme.Selected))
+			case b.Highlights[sqNum] == HIGHLIGHT_CURSOR:
+				fmt.Fprint(out, tui.BgCode(theme.Cursor))
+			case sqNum == b.CheckSq:
+				fmt.Fprint(out, tui.BgCode(theme.Check))
+			case sqNum == b.LastMoveSrcSq || sqNum == b.LastMoveTrgSq:
+				fmt.Fprint(out, tui.BgCode(theme.LastMove))
+			case SquareIsLight(sqNum):
+				fmt.Fprint(out, tui.BgCode(theme.LightSquare))
+			default:
+				fmt.Fprint(out, tui.BgCode(theme.DarkSquare))
 			}
 
-			if isHighlight {
-				fmt.Fprintf(out, "\u001b[48;5;81m")
+			if i%sqH == pieceLine {
+				fmt.Fprintf(out, "%s%c%s", padLeft, pieceRune, padRight)
 			} else {
-				fmt.Fprintf(out, "\u001b[48;5;24m")
+				fmt.Fprint(out, blank)
 			}
-
+			tui.ResetStyle()
 		}
+	}
 
-		fmt.Fprintf(out, "%c ", pieceRune)
-		tui.ResetStyle()
+	files := "abcdefgh"
+	if rotated {
+		files = "hgfedcba"
 	}
+	tui.MoveCursorTo(l.Board.Row+8*sqH, l.Board.Col)
+	fmt.Fprint(out, "   ")
+	for _, f := range files {
+		fmt.Fprintf(out, "%s%c%s", padLeft, f, padRight)
+	}
+}
 
-	fmt.Fprintf(out, "\n")
+// Writes the board without any escape sequences, using FEN letters for the
+// pieces and '.' for empty squares.
+func (b Board) DisplayASCII(out io.Writer, rotated bool) {
+	for rankIdx := 0; rankIdx < 8; rankIdx++ {
+		rank := 8 - rankIdx
+		if rotated {
+			rank = 1 + rankIdx
+		}
+		fmt.Fprintf(out, "%d ", rank)
+
+		for fileIdx := 0; fileIdx < 8; fileIdx++ {
+			sqNum := rankIdx*8 + fileIdx
+			if rotated {
+				sqNum = 63 - sqNum
+			}
+
+			if b.Pieces[sqNum] == piece.EMPTYP {
+				fmt.Fprint(out, " .")
+			} else {
+				fmt.Fprintf(out, " %c", piece.ToFenChar[b.Pieces[sqNum]])
+			}
+		}
+		fmt.Fprintln(out)
+	}
 
 	if rotated {
-		fmt.Fprint(out, "   h g f e d c b a\n")
+		fmt.Fprintln(out, "   h g f e d c b a")
 	} else {
-		fmt.Fprint(out, "   a b c d e f g h\n")
+		fmt.Fprintln(out, "   a b c d e f g h")
 	}
 }
 
 func (b Board) CanCastleShort(c piece.Color) bool {
-	if c == piece.WHITE {
-		return b.CastleRights&CASTLE_WHITE_SHORT == CASTLE_WHITE_SHORT
-	} else {
-		return b.CastleRights&CASTLE_BLACK_SHORT == CASTLE_BLACK_SHORT
-	}
+	return b.CastleRights&CastleRight(c, true) != 0
 }
 
 func (b Board) CanCastleLong(c piece.Color) bool {
-	if c == piece.WHITE {
-		return b.CastleRights&CASTLE_WHITE_LONG == CASTLE_WHITE_LONG
-	} else {
-		return b.CastleRights&CASTLE_BLACK_LONG == CASTLE_BLACK_LONG
-	}
+	return b.CastleRights&CastleRight(c, false) != 0
+}
+
+// Returns the file of the rook the player castles with on the given side
+func (b Board) CastleRookFile(c piece.Color, short bool) byte {
+	return b.CastleRookFiles[castleIndex(c, short)]
+}
+
+// Sets the castle right of the player on the given side with the rook on file
+func (b *Board) SetCastleRook(c piece.Color, short bool, file byte) {
+	b.CastleRights |= CastleRight(c, short)
+	b.CastleRookFiles[castleIndex(c, short)] = file
 }
 
 func (b Board) AllMoves(player piece.Color) []Move {
+	return append(b.boardMoves(player), b.DropMoves(player)...)
+}
+
+// Moves of the pieces on the board, without drops
+func (b Board) boardMoves(player piece.Color) []Move {
 	allMoves := []Move{}
 	allMoves = append(allMoves, b.PawnMoves(player)...)
 	allMoves = append(allMoves, b.KingMoves(player)...)
@@ -126,6 +239,27 @@ func (b Board) AllValidMoves(c piece.Color) []Move {
 	return validMoves
 }
 
+// Returns the valid moves of the piece on the given square
+func (b Board) ValidMovesFrom(sqNum int, c piece.Color) []Move {
+	moves := make([]Move, 0)
+	for _, mv := range b.AllValidMoves(c) {
+		if mv.SrcSqNum() == sqNum {
+			moves = append(moves, mv)
+		}
+	}
+	return moves
+}
+
+func (b *Board) ClearLastMove() {
+	b.LastMoveSrcSq = -1
+	b.LastMoveTrgSq = -1
+	b.CheckSq = -1
+}
+
+func (b *Board) ClearHighlights() {
+	b.Highlights = [64]Highlight{}
+}
+
 func SquareIsLight(sqNum int) bool {
 	return (sqNum+sqNum/8)%2 == 0
 }
@@ -163,8 +297,16 @@ func (b Board) PawnMoves(color piece.Color) []Move {
 			mv := Move{}
 			mv.Piece = piece.PAWN
 			mv.SetSrcFromSqNum(i)
-			if mv.SetTrgDeltaAndCheckBounds(d[0], d[1]) {
-				mv.Capture = b.Pieces[mv.TrgSqNum()] != piece.EMPTYP
+			if !mv.SetTrgDeltaAndCheckBounds(d[0], d[1]) {
+				continue
+			}
+			mv.Capture = b.Pieces[mv.TrgSqNum()] != piece.EMPTYP
+			if !mv.IsPromotion() {
+				moves = append(moves, mv)
+				continue
+			}
+			for _, promote := range b.Rules().Promotions() {
+				mv.Promote = promote
 				moves = append(moves, mv)
 			}
 		}
@@ -198,6 +340,52 @@ func (b Board) KnightMoves(color piece.Color) []Move {
 	return moves
 }
 
+// Drops of the pieces in the player's pocket on the empty squares. Pawns are
+// not dropped on the first or last rank.
+func (b Board) DropMoves(color piece.Color) []Move {
+	moves := []Move{}
+	if !b.Rules().Drops() {
+		return moves
+	}
+	for t, count := range b.Pockets[color] {
+		if count == 0 {
+			continue
+		}
+		for sq, p := range b.Pieces {
+			if p != piece.EMPTYP {
+				continue
+			}
+			mv := Move{Piece: piece.Type(t), Drop: true, DropColor: color}
+			mv.SetTrgFromSqNum(sq)
+			if mv.Piece != piece.PAWN || !mv.IsPromotion() {
+				moves = append(moves, mv)
+			}
+		}
+	}
+	return moves
+}
+
+func (b Board) validateDrop(mv Move, color piece.Color) (bool, string) {
+	name := strings.ToLower(piece.PieceNames[mv.Piece])
+	switch {
+	case !b.Rules().Drops():
+		return false, "Pieces can only be dropped in Crazyhouse"
+	case mv.Piece == piece.NONE || mv.Piece == piece.KING || b.Pockets[color][mv.Piece] == 0:
+		return false, fmt.Sprintf("There is no %s in your pocket", name)
+	case b.Pieces[mv.TrgSqNum()] != piece.EMPTYP:
+		return false, fmt.Sprintf("%s is not empty", SqNumToStr(mv.TrgSqNum()))
+	case mv.Piece == piece.PAWN && mv.IsPromotion():
+		return false, "Pawns can not be dropped on the first or last rank"
+	}
+
+	mv.DropColor = color
+	b.UpdateBoardWithMove(mv)
+	if b.IsInCheck(color) {
+		return false, "Your king would be in check"
+	}
+	return true, ""
+}
+
 func (b Board) KingMoves(color piece.Color) []Move {
 	var deltas = [8][2]int{
 		{0, 1}, {1, 1}, {1, 0}, {1, -1},
@@ -222,31 +410,103 @@ func (b Board) KingMoves(color piece.Color) []Move {
 
 	}
 
-	// Short castle
-	m := Move{}
-	m.Piece = piece.KING
-	if color == piece.WHITE {
-		m.SetSrcFromAlphaNum("e1")
-		m.SetTrgFromAlphaNum("g1")
-	} else {
-		m.SetSrcFromAlphaNum("e8")
-		m.SetTrgFromAlphaNum("g8")
+	for _, short := range []bool{true, false} {
+		if m, ok := b.castleMove(color, short); ok {
+			moves = append(moves, m)
+		}
 	}
-	moves = append(moves, m)
+	return moves
+}
 
-	// Long castle
-	m = Move{}
-	m.Piece = piece.KING
+// Returns the castling move on the given side if the king is on its first
+// rank. The king ends on the g- or c-file and the rook next to it on the f- or
+// d-file, wherever they started. ValidateMove checks the castle rights.
+func (b Board) castleMove(color piece.Color, short bool) (Move, bool) {
+	rank := backRank(color)
+	for file := byte('a'); file <= 'h'; file++ {
+		p := b.Pieces[StrToSqNum(string([]byte{file, rank}))]
+		if p.Type != piece.KING || p.Color != color {
+			continue
+		}
+		m := Move{Piece: piece.KING, SrcFile: file, SrcRank: rank, TrgFile: 'c', TrgRank: rank, Castle: true}
+		if short {
+			m.TrgFile = 'g'
+		}
+		m.RookFile = b.CastleRookFile(color, short)
+		return m, true
+	}
+	return Move{}, false
+}
+
+func backRank(color piece.Color) byte {
 	if color == piece.WHITE {
-		m.SetSrcFromAlphaNum("e1")
-		m.SetTrgFromAlphaNum("c1")
-	} else {
-		m.SetSrcFromAlphaNum("e8")
-		m.SetTrgFromAlphaNum("c8")
+		return '1'
 	}
-	moves = append(moves, m)
+	return '8'
+}
 
-	return moves
+// Castling is legal if neither the king nor the rook has moved, every square
+// they cross or land on is empty apart from themselves, and the king is not in
+// check on its way
+func (b Board) validateCastle(mv Move, color piece.Color) (bool, string) {
+	short := mv.IsShortCastle()
+	side := "long"
+	if short {
+		side = "short"
+	}
+	if b.CastleRights&CastleRight(color, short) == 0 || b.CastleRookFile(color, short) != mv.RookFile {
+		return false, fmt.Sprintf("Can no longer %s castle", side)
+	}
+	kingSq := mv.SrcSqNum()
+	rookSq := StrToSqNum(string([]byte{mv.RookFile, mv.SrcRank}))
+	rookTrgSq := StrToSqNum(string([]byte{mv.RookTrgFile(), mv.SrcRank}))
+	if b.Pieces[kingSq] != (piece.Piece{Type: piece.KING, Color: color}) ||
+		b.Pieces[rookSq] != (piece.Piece{Type: piece.ROOK, Color: color}) {
+		return false, fmt.Sprintf("Can no longer %s castle", side)
+	}
+
+	for _, path := range [][2]int{{kingSq, mv.TrgSqNum()}, {rookSq, rookTrgSq}} {
+		from, to := path[0], path[1]
+		if from > to {
+			from, to = to, from
+		}
+		for sq := from; sq <= to; sq++ {
+			if sq != kingSq && sq != rookSq && b.Pieces[sq] != piece.EMPTYP {
+				return false, fmt.Sprintf("A %s on %s is blocking your path", b.Pieces[sq].Name(), SqNumToStr(sq))
+			}
+		}
+	}
+
+	step := 1
+	if mv.TrgSqNum() < kingSq {
+		step = -1
+	}
+	withoutKing := b
+	withoutKing.Pieces[kingSq] = piece.EMPTYP
+	for sq := kingSq; ; sq += step {
+		withoutKing.Pieces[sq] = b.Pieces[kingSq]
+		if withoutKing.IsInCheck(color) {
+			if sq == kingSq {
+				return false, "You can not castle out of check"
+			}
+			return false, "You can not castle through or into check"
+		}
+		withoutKing.Pieces[sq] = b.Pieces[sq]
+		if sq == kingSq {
+			withoutKing.Pieces[sq] = piece.EMPTYP
+		}
+		if sq == mv.TrgSqNum() {
+			break
+		}
+	}
+
+	after := b
+	after.CapturedPieces = nil
+	after.UpdateBoardWithMove(mv)
+	if after.IsInCheck(color) {
+		return false, "Your king would be in check"
+	}
+	return true, ""
 }
 
 func (b Board) RookMoves(color piece.Color) []Move {
@@ -317,15 +577,11 @@ func (b Board) ValidateMove(mv Move, color piece.Color) (ok bool, msg string) {
 	trgSq := mv.TrgSqNum()
 	srcSq := mv.SrcSqNum()
 
-	// TODO: Verify that castling doesn't move through or into check
-	shortCastle := mv.IsShortCastle()
-	longCastle := mv.IsLongCastle()
-	if shortCastle && !b.CanCastleShort(color) {
-		msg = "Can no longer short castle"
-		ok = false
-	} else if longCastle && !b.CanCastleLong(color) {
-		msg = "Can no longer long castle"
-		ok = false
+	if mv.Castle {
+		return b.validateCastle(mv, color)
+	}
+	if mv.Drop {
+		return b.validateDrop(mv, color)
 	}
 
 	if ok && b.Pieces[mv.SrcSqNum()].Color == b.Pieces[trgSq].Color {
@@ -344,10 +600,11 @@ func (b Board) ValidateMove(mv Move, color piece.Color) (ok bool, msg string) {
 
 	if ok && mv.Piece == piece.PAWN {
 		dx, dy := mv.MoveDelta()
-		if b.Pieces[srcSq].Color == piece.WHITE && mv.SrcRank != '2' && dy == 2 {
+		// Pawns on the first rank, as in Horde, may also move two spaces
+		if b.Pieces[srcSq].Color == piece.WHITE && mv.SrcRank > '2' && dy == 2 {
 			msg = "A pawn can only move two spaces on its first move"
 			ok = false
-		} else if b.Pieces[srcSq].Color == piece.BLACK && mv.SrcRank != '7' && dy == -2 {
+		} else if b.Pieces[srcSq].Color == piece.BLACK && mv.SrcRank < '7' && dy == -2 {
 			msg = "A pawn can only move two spaces on its first move"
 			ok = false
 		} else if b.Pieces[trgSq].Type != piece.NONE && dx == 0 {
@@ -356,11 +613,22 @@ func (b Board) ValidateMove(mv Move, color piece.Color) (ok bool, msg string) {
 		} else if b.Pieces[trgSq].Type == piece.NONE && dx != 0 {
 			msg = "Pawns can only move diagonally to capture"
 			ok = false
+		} else if mv.IsPromotion() && mv.Promote == piece.NONE {
+			msg = "Must specify the piece for pawn promotion"
+			ok = false
+		} else if mv.IsPromotion() && !b.canPromoteTo(mv.Promote) {
+			msg = fmt.Sprintf("A pawn can not promote to a %s", strings.ToLower(piece.PieceNames[mv.Promote]))
+			ok = false
 		}
-		// else if mv.TrgRank == '1' || mv.TrgRank == '8' && mv.Promote == piece.NONE {
-		// 	msg = "Must specify the piece for pawn promotion"
-		// 	ok = false
-		// }
+	}
+
+	if ok && mv.Promote != piece.NONE && !mv.IsPromotion() {
+		msg = "Only a pawn reaching the last rank can promote"
+		ok = false
+	}
+
+	if ok {
+		ok, msg = b.Rules().AllowMove(b, mv, color)
 	}
 
 	// Check if the move results in a check
@@ -377,21 +645,25 @@ func (b Board) ValidateMove(mv Move, color piece.Color) (ok bool, msg string) {
 }
 
 func (b Board) FindKing(color piece.Color) int {
-	kingSq := -1
-	for sqNum, p := range b.Pieces {
-		if p.Type == piece.KING && p.Color == color {
-			kingSq = sqNum
-			break
-		}
-	}
-
-	if kingSq == -1 {
+	kingSq, ok := b.KingSq(color)
+	if !ok {
 		panic("King missing from board")
 	}
 
 	return kingSq
 }
 
+// Returns the square of the player's king. Players of some variants, such as
+// white in Horde, have none.
+func (b Board) KingSq(color piece.Color) (int, bool) {
+	for sqNum, p := range b.Pieces {
+		if p.Type == piece.KING && p.Color == color {
+			return sqNum, true
+		}
+	}
+	return -1, false
+}
+
 func (b Board) moveRevealsCheck(color piece.Color, mv Move) bool {
 	revealsCheck := false
 	kingSq := b.FindKing(color)
@@ -487,46 +759,56 @@ func (b *Board) UpdateBoardWithMove(mv Move) {
 	trg := mv.TrgSqNum()
 	src := mv.SrcSqNum()
 
-	if b.Pieces[trg] != piece.EMPTYP {
-		b.CapturedPieces = append(b.CapturedPieces, b.Pieces[trg])
+	// Special case -- castling also moves the rook. In Chess960 the king may
+	// land on the square of the rook, so both are lifted first.
+	if mv.Castle {
+		rookSq := StrToSqNum(string([]byte{mv.RookFile, mv.SrcRank}))
+		king, rook := b.Pieces[src], b.Pieces[rookSq]
+		b.Pieces[src] = piece.EMPTYP
+		b.Pieces[rookSq] = piece.EMPTYP
+		b.Pieces[trg] = king
+		b.Pieces[StrToSqNum(string([]byte{mv.RookTrgFile(), mv.SrcRank}))] = rook
+		return
+	}
+
+	if mv.Drop {
+		b.Pieces[trg] = piece.Piece{Type: mv.Piece, Color: mv.DropColor}
+		b.Pockets[mv.DropColor][mv.Piece]--
+		return
+	}
+
+	if captured := b.Pieces[trg]; captured != piece.EMPTYP {
+		b.CapturedPieces = append(b.CapturedPieces, captured)
+		if b.Rules().Drops() {
+			// The piece changes sides, a promoted one back into a pawn
+			t := captured.Type
+			if b.Promoted[trg] {
+				t = piece.PAWN
+			}
+			b.Pockets[captured.Color.Opposite()][t]++
+		}
 	}
 
 	b.Pieces[trg] = b.Pieces[src]
 	b.Pieces[src] = piece.EMPTYP
+	b.Promoted[trg] = b.Promoted[src] || mv.IsPromotion()
+	b.Promoted[src] = false
 
-	// Special case -- castling also moves Rook
-	if mv.Piece == piece.KING && src == 60 && trg == 62 {
-		b.Pieces[63] = piece.EMPTYP
-		b.Pieces[61] = piece.ROOK_W
-	} else if mv.Piece == piece.KING && src == 60 && trg == 58 {
-		b.Pieces[56] = piece.EMPTYP
-		b.Pieces[59] = piece.ROOK_W
-	} else if mv.Piece == piece.KING && src == 4 && trg == 6 {
-		b.Pieces[7] = piece.EMPTYP
-		b.Pieces[5] = piece.ROOK_B
-	} else if mv.Piece == piece.KING && src == 4 && trg == 2 {
-		b.Pieces[0] = piece.EMPTYP
-		b.Pieces[3] = piece.ROOK_B
-	}
-
-	// Special case -- pawn promotion
-	if mv.Piece == piece.PAWN && (mv.TrgRank == '1' || mv.TrgRank == '8') {
-		promoPiece := piece.Piece{}
-		if mv.Promote == piece.NONE {
-			promoPiece.Type = piece.QUEEN
-			promoPiece.Color = b.Pieces[trg].Color
-		} else {
-			promoPiece.Type = mv.Promote
-			promoPiece.Color = b.Pieces[trg].Color
-		}
-		b.Pieces[trg] = promoPiece
+	// Special case -- pawn promotion. ValidateMove makes sure that the piece
+	// is given.
+	if mv.IsPromotion() && mv.Promote != piece.NONE {
+		b.Pieces[trg] = piece.Piece{Type: mv.Promote, Color: b.Pieces[trg].Color}
 	}
 }
 
 func CreateDefault() Board {
 	b := Board{}
 	b.Pieces = DefaultBoard
-	b.CastleRights = 0b1111
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		b.SetCastleRook(color, true, 'h')
+		b.SetCastleRook(color, false, 'a')
+	}
+	b.ClearLastMove()
 	return b
 }
 
@@ -551,6 +833,14 @@ type Move struct {
 	TrgFile byte
 	Promote piece.Type
 	Capture bool
+	// The king castles with the rook on RookFile. The target is where the
+	// king ends up, the g- or c-file.
+	Castle   bool
+	RookFile byte
+	// The piece is dropped from the pocket of DropColor onto the target, as in
+	// Crazyhouse. Parsed moves leave DropColor to the board.
+	Drop      bool
+	DropColor piece.Color
 }
 
 func (m *Move) SetSrcFromAlphaNum(alphaNum string) {
@@ -588,19 +878,30 @@ func (m *Move) MoveDelta() (int, int) {
 }
 
 func (m *Move) IsShortCastle() bool {
-	isKing := m.Piece == piece.KING
-	whiteShortCastle := m.SrcSqNum() == 60 && m.TrgSqNum() == 62
-	blackShortCastle := m.SrcSqNum() == 4 && m.TrgSqNum() == 6
-	isShortCastle := whiteShortCastle || blackShortCastle
-	return isKing && isShortCastle
+	return m.Castle && m.TrgFile == 'g'
 }
 
 func (m *Move) IsLongCastle() bool {
-	isKing := m.Piece == piece.KING
-	whiteLongCastle := m.SrcSqNum() == 60 && m.TrgSqNum() == 58
-	blackLongCastle := m.SrcSqNum() == 4 && m.TrgSqNum() == 2
-	isLongCastle := whiteLongCastle || blackLongCastle
-	return isKing && isLongCastle
+	return m.Castle && m.TrgFile == 'c'
+}
+
+// File the rook ends up on when castling
+func (m *Move) RookTrgFile() byte {
+	if m.IsShortCastle() {
+		return 'f'
+	}
+	return 'd'
+}
+
+// Square of the rook a castling move takes along
+func (m *Move) RookSqNum() int {
+	return StrToSqNum(string([]byte{m.RookFile, m.SrcRank}))
+}
+
+// A castling move can be told apart from the king's other moves by its target
+// unless the king moves a single square or not at all, as it may in Chess960
+func (m *Move) castleShownByTarget() bool {
+	return Abs(int(m.TrgFile)-int(m.SrcFile)) >= 2
 }
 
 func (m *Move) SlideMoves(b Board, d [2]int) []Move {
@@ -636,6 +937,8 @@ func (m *Move) Equals(other Move) bool {
 	isEqual = isEqual && (m.TrgRank == other.TrgRank)
 	isEqual = isEqual && (m.TrgFile == other.TrgFile)
 	isEqual = isEqual && (m.Promote == other.Promote)
+	isEqual = isEqual && (m.Castle == other.Castle)
+	isEqual = isEqual && (m.Drop == other.Drop)
 	return isEqual
 }
 
@@ -643,16 +946,152 @@ func (m *Move) ToStr() string {
 	return fmt.Sprintf("%s from %c%c to %c%c", piece.PieceNames[m.Piece], m.SrcFile, m.SrcRank, m.TrgFile, m.TrgRank)
 }
 
+// Long algebraic notation as used by UCI, e.g. e2e4 or e7e8q. Castling is
+// written as the king's move (e1g1), or as the king taking its own rook (b1a1)
+// when that would look like an ordinary king move.
+func (m *Move) ToLAN() string {
+	if m.Drop {
+		return fmt.Sprintf("%c@%c%c", piece.ToFenChar[piece.Piece{Type: m.Piece, Color: piece.WHITE}], m.TrgFile, m.TrgRank)
+	}
+	if m.Castle && !m.castleShownByTarget() {
+		return fmt.Sprintf("%c%c%c%c", m.SrcFile, m.SrcRank, m.RookFile, m.SrcRank)
+	}
+	lan := fmt.Sprintf("%c%c%c%c", m.SrcFile, m.SrcRank, m.TrgFile, m.TrgRank)
+	if m.Promote != piece.NONE {
+		lan += string(piece.ToFenChar[piece.Piece{Type: m.Promote, Color: piece.BLACK}])
+	}
+	return lan
+}
+
+// Returns true for a pawn move to the last rank
+func (m *Move) IsPromotion() bool {
+	return m.Piece == piece.PAWN && (m.TrgRank == '1' || m.TrgRank == '8')
+}
+
+// Figurine of the piece and the target, e.g. ♘xd7. Castling and drops are
+// written by ToLongAlgebraic.
+//
+// Deprecated: Use Board.ToSAN, which also disambiguates and marks checks.
 func (m *Move) ToShortStr() string {
+	if m.Castle || m.Drop {
+		return m.ToLongAlgebraic()
+	}
+	sep := ' '
 	if m.Capture {
-		return fmt.Sprintf("%cx%c%c", piece.PieceRunesFilled[m.Piece], m.TrgFile, m.TrgRank)
-	} else {
-		return fmt.Sprintf("%c %c%c", piece.PieceRunesFilled[m.Piece], m.TrgFile, m.TrgRank)
+		sep = 'x'
+	}
+	return fmt.Sprintf("%c%c%c%c", piece.PieceRunesFilled[m.Piece], sep, m.TrgFile, m.TrgRank)
+}
+
+// Long algebraic notation with the piece letter, e.g. Nb1-d2 or e7xd8=Q
+func (m *Move) ToLongAlgebraic() string {
+	var sb strings.Builder
+	letter := piece.ActiveLanguage.Letter(m.Piece)
+	if m.Drop && letter == 'P' {
+		// P@ is read as a pawn drop
+		letter = piece.Languages["en"].Letter(m.Piece)
 	}
+	if letter != 0 {
+		sb.WriteByte(letter)
+	}
+	if m.Drop {
+		fmt.Fprintf(&sb, "@%c%c", m.TrgFile, m.TrgRank)
+		return sb.String()
+	}
+	sep := '-'
+	if m.Capture {
+		sep = 'x'
+	}
+	fmt.Fprintf(&sb, "%c%c%c%c%c", m.SrcFile, m.SrcRank, sep, m.TrgFile, m.TrgRank)
+	if m.Promote != piece.NONE {
+		fmt.Fprintf(&sb, "=%c", piece.ActiveLanguage.Letter(m.Promote))
+	}
+	return sb.String()
+}
+
+// Standard algebraic notation of a legal move in this position, e.g. Nbd7,
+// exd5, e8=Q+ or O-O-O#. The pieces are written as piece.MoveSymbol does, so
+// this is figurine algebraic notation (♘bd7) if piece.MoveFigurines is set.
+func (b Board) ToSAN(mv Move) string {
+	color := mv.DropColor
+	if !mv.Drop {
+		color = b.Pieces[mv.SrcSqNum()].Color
+	}
+	var sb strings.Builder
+	switch {
+	case mv.Drop:
+		// Pawns have no letter, so their drops are written @e4. P@ is read as a
+		// pawn drop, so the Dutch knight is written N@ instead.
+		symbol := piece.MoveSymbol(mv.Piece)
+		if symbol == "P" {
+			symbol = string(piece.Languages["en"].Letter(mv.Piece))
+		}
+		fmt.Fprintf(&sb, "%s@%c%c", symbol, mv.TrgFile, mv.TrgRank)
+	case mv.IsShortCastle():
+		sb.WriteString("O-O")
+	case mv.IsLongCastle():
+		sb.WriteString("O-O-O")
+	case mv.Piece == piece.PAWN:
+		if mv.Capture {
+			fmt.Fprintf(&sb, "%cx", mv.SrcFile)
+		}
+		fmt.Fprintf(&sb, "%c%c", mv.TrgFile, mv.TrgRank)
+		if mv.Promote != piece.NONE {
+			fmt.Fprintf(&sb, "=%s", piece.MoveSymbol(mv.Promote))
+		}
+	default:
+		sb.WriteString(piece.MoveSymbol(mv.Piece))
+		sb.WriteString(b.disambiguation(mv, color))
+		if mv.Capture {
+			sb.WriteByte('x')
+		}
+		fmt.Fprintf(&sb, "%c%c", mv.TrgFile, mv.TrgRank)
+	}
+
+	after := b
+	after.CapturedPieces = nil
+	after.UpdateBoardWithMove(mv)
+	opponent := color.Opposite()
+	if after.IsInCheck(opponent) && len(after.AllValidMoves(opponent)) == 0 {
+		sb.WriteByte('#')
+	} else if after.IsInCheck(opponent) {
+		sb.WriteByte('+')
+	}
+	return sb.String()
+}
+
+// Returns the file, rank or square that tells the moving piece apart from
+// the other pieces of its kind that can reach the same square
+func (b Board) disambiguation(mv Move, color piece.Color) string {
+	others, sameFile, sameRank := 0, false, false
+	for _, other := range b.AllValidMoves(color) {
+		if other.Piece != mv.Piece || other.TrgSqNum() != mv.TrgSqNum() || other.SrcSqNum() == mv.SrcSqNum() {
+			continue
+		}
+		others++
+		sameFile = sameFile || other.SrcFile == mv.SrcFile
+		sameRank = sameRank || other.SrcRank == mv.SrcRank
+	}
+
+	switch {
+	case others == 0:
+		return ""
+	case !sameFile:
+		return string(mv.SrcFile)
+	case !sameRank:
+		return string(mv.SrcRank)
+	}
+	return string([]byte{mv.SrcFile, mv.SrcRank})
 }
 
 func (m *Move) Matches(wantedMv Move) bool {
 	pieceMatches := m.Piece == wantedMv.Piece
+	if m.Castle {
+		return m.matchesCastle(wantedMv)
+	}
+	if m.Drop || wantedMv.Drop {
+		return m.Drop && wantedMv.Drop && pieceMatches && m.TrgSqNum() == wantedMv.TrgSqNum()
+	}
 	if m.TrgSqNum() == wantedMv.TrgSqNum() && m.SrcSqNum() == wantedMv.SrcSqNum() {
 		// This is for long algebraic notation where a source square and
 		// target square are specified.
@@ -665,14 +1104,26 @@ func (m *Move) Matches(wantedMv Move) bool {
 			return false
 		}
 		return true
-	} else if pieceMatches && m.Piece == piece.KING && m.SrcFile == wantedMv.SrcFile && m.TrgFile == wantedMv.TrgFile {
-		// Castling
-		return true
 	}
 
 	return false
 }
 
+// Castling is written O-O or O-O-O, as the king's move in long algebraic
+// notation (e1g1), or as the king taking its own rook (e1h1)
+func (m *Move) matchesCastle(wantedMv Move) bool {
+	if wantedMv.Castle {
+		return m.TrgFile == wantedMv.TrgFile
+	}
+	if wantedMv.Piece != piece.KING && wantedMv.Piece != piece.NONE {
+		return false
+	}
+	if wantedMv.SrcFile != m.SrcFile || wantedMv.SrcRank != m.SrcRank || wantedMv.TrgRank != m.SrcRank {
+		return false
+	}
+	return wantedMv.TrgFile == m.RookFile || (wantedMv.TrgFile == m.TrgFile && m.castleShownByTarget())
+}
+
 func SqNumToStr(sqNum int) string {
 	rank := '8' - byte(sqNum/8)
 	file := 'a' + byte(sqNum%8)
@@ -689,10 +1140,15 @@ func (b Board) IsInCheck(c piece.Color) bool {
 	// Just check if the moved piece is putting the king in check
 	// OR if the moved piece reveals a check on the enemy.
 	inCheck := false
-	enemyMoves := b.AllMoves(c.Opposite())
-	kingSq := b.FindKing(c)
+	kingSq, ok := b.KingSq(c)
+	if !ok || !b.Rules().RoyalKings() {
+		return false
+	}
+	// Dropped pieces never capture
+	enemyMoves := b.boardMoves(c.Opposite())
 	for _, mv := range enemyMoves {
-		if mv.TrgSqNum() == kingSq {
+		// Castling never captures
+		if mv.TrgSqNum() == kingSq && !mv.Castle {
 			if ok, _ := b.ValidateMove(mv, c.Opposite()); ok {
 				inCheck = true
 				break
@@ -714,25 +1170,43 @@ func (b Board) FindMatchingMove(wantedMv Move, c piece.Color) (Move, error) {
 	move := Move{}
 	var err error
 
+	// A drop names both piece and square, so it is checked as it is to tell
+	// why it is not allowed
+	if wantedMv.Drop {
+		wantedMv.DropColor = c
+		if ok, msg := b.ValidateMove(wantedMv, c); !ok {
+			return move, fmt.Errorf(msg)
+		}
+		return wantedMv, nil
+	}
+
 	allMoves := b.AllMoves(c)
 	matchingMoves := []Move{}
 	for _, mv := range allMoves {
-		if mv.Matches(wantedMv) {
-			if ok, msg := b.ValidateMove(mv, c); ok {
-				mv.Promote = wantedMv.Promote
-				matchingMoves = append(matchingMoves, mv)
-			} else {
-				err = fmt.Errorf(msg)
-			}
+		if !mv.Matches(wantedMv) {
+			continue
+		}
+		if wantedMv.Promote != piece.NONE && mv.Promote != wantedMv.Promote {
+			continue
 		}
+		if ok, msg := b.ValidateMove(mv, c); ok {
+			matchingMoves = append(matchingMoves, mv)
+		} else {
+			err = fmt.Errorf(msg)
+		}
+	}
+	if wantedMv.Promote == piece.NONE {
+		matchingMoves = withoutPromotions(matchingMoves)
 	}
 
-	if len(matchingMoves) == 1 {
+	if len(matchingMoves) == 1 && matchingMoves[0].IsPromotion() && matchingMoves[0].Promote == piece.NONE {
+		move = matchingMoves[0]
+		err = &PromotionRequiredError{Move: move}
+	} else if len(matchingMoves) == 1 {
 		move = matchingMoves[0]
 		err = nil
 	} else if len(matchingMoves) > 1 {
-		err = fmt.Errorf("Ambiguous move")
-		// TODO: Ask which piece they meant to move
+		err = &AmbiguousMoveError{Candidates: matchingMoves}
 	} else if err == nil {
 		// No move candidates were found
 		err = fmt.Errorf("Illegal move")
@@ -741,23 +1215,74 @@ func (b Board) FindMatchingMove(wantedMv Move, c piece.Color) (Move, error) {
 	return move, err
 }
 
+// AmbiguousMoveError is returned when more than one piece can make the move.
+// The candidates are all legal, so the player only has to pick one.
+type AmbiguousMoveError struct {
+	Candidates []Move
+}
+
+func (e *AmbiguousMoveError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, mv := range e.Candidates {
+		names[i] = mv.ToLongAlgebraic()
+	}
+	last := len(names) - 1
+	question := strings.Join(names[:last], ", ") + " or " + names[last]
+	return fmt.Sprintf("%s? Enter the square of the piece to move", question)
+}
+
+// PromotionRequiredError is returned for a pawn reaching the last rank when
+// the move does not say what it promotes to. Move is the move without the
+// promotion piece.
+type PromotionRequiredError struct {
+	Move Move
+}
+
+func (e *PromotionRequiredError) Error() string {
+	return fmt.Sprintf("Promote to a queen, rook, bishop or knight? Enter %s", piece.ActiveLanguage.PromotionLetters())
+}
+
+func (b Board) canPromoteTo(t piece.Type) bool {
+	for _, promote := range b.Rules().Promotions() {
+		if t == promote {
+			return true
+		}
+	}
+	return false
+}
+
+// Turns the moves that only differ in the promotion piece into a single move
+// without one, so that they are not mistaken for different pieces
+func withoutPromotions(moves []Move) []Move {
+	unique := make([]Move, 0, len(moves))
+	for _, mv := range moves {
+		mv.Promote = piece.NONE
+		seen := false
+		for _, other := range unique {
+			seen = seen || (other.SrcSqNum() == mv.SrcSqNum() && other.TrgSqNum() == mv.TrgSqNum())
+		}
+		if !seen {
+			unique = append(unique, mv)
+		}
+	}
+	return unique
+}
+
 // TODO: Make receivers uniform
+// Takes away the castle rights a move ends: those of a king that moves, and
+// those of a rook that moves or is captured
 func (b *Board) UpdateCastleRightsWithMove(mv Move, c piece.Color) {
-	if mv.Piece == piece.KING && c == piece.WHITE {
-		b.CastleRights &= ^(CASTLE_WHITE_SHORT | CASTLE_WHITE_LONG)
-	} else if mv.Piece == piece.KING && c == piece.BLACK {
-		b.CastleRights &= ^(CASTLE_BLACK_SHORT | CASTLE_BLACK_LONG)
-	} else if mv.Piece == piece.ROOK && c == piece.WHITE {
-		if mv.SrcSqNum() == 63 {
-			b.CastleRights &= ^CASTLE_WHITE_SHORT
-		} else if mv.SrcSqNum() == 56 {
-			b.CastleRights &= ^CASTLE_WHITE_LONG
-		}
-	} else if mv.Piece == piece.ROOK && c == piece.BLACK {
-		if mv.SrcSqNum() == 7 {
-			b.CastleRights &= ^CASTLE_BLACK_SHORT
-		} else if mv.SrcSqNum() == 0 {
-			b.CastleRights &= ^CASTLE_BLACK_LONG
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		for _, short := range []bool{true, false} {
+			right := CastleRight(color, short)
+			if b.CastleRights&right == 0 {
+				continue
+			}
+			rookSq := StrToSqNum(string([]byte{b.CastleRookFile(color, short), backRank(color)}))
+			kingMoved := color == c && mv.Piece == piece.KING
+			if kingMoved || mv.SrcSqNum() == rookSq || (mv.TrgSqNum() == rookSq && !mv.Castle) {
+				b.CastleRights &= ^right
+			}
 		}
 	}
 }
@@ -0,0 +1,91 @@
+package board
+
+import "github.com/Jesselli/tchess/piece"
+
+// Mating tells whether a player's pieces are enough to checkmate
+type Mating int
+
+const (
+	MATING_NONE   Mating = iota // No series of legal moves ends in mate
+	MATING_HELP                 // Mate is possible, but only if the opponent helps
+	MATING_FORCED               // Mate can be forced, or a pawn may promote
+)
+
+// Material of one player, kings not included
+type material struct {
+	pawns, knights, rooks, queens int
+	lightBishops, darkBishops     int
+}
+
+func (b Board) material(color piece.Color) material {
+	var m material
+	for sq, p := range b.Pieces {
+		if p.Color != color {
+			continue
+		}
+		switch p.Type {
+		case piece.PAWN:
+			m.pawns++
+		case piece.KNIGHT:
+			m.knights++
+		case piece.BISHOP:
+			if SquareIsLight(sq) {
+				m.lightBishops++
+			} else {
+				m.darkBishops++
+			}
+		case piece.ROOK:
+			m.rooks++
+		case piece.QUEEN:
+			m.queens++
+		}
+	}
+	return m
+}
+
+func (m material) pieces() int {
+	return m.pawns + m.knights + m.lightBishops + m.darkBishops + m.rooks + m.queens
+}
+
+// Classifies the material of the player against the pieces of the opponent.
+// Pawns count as sufficient because they can promote.
+func (b Board) Mating(color piece.Color) Mating {
+	own := b.material(color)
+	opp := b.material(color.Opposite())
+	bishops := own.lightBishops + own.darkBishops
+
+	switch {
+	case own.pawns > 0 || own.rooks > 0 || own.queens > 0:
+		return MATING_FORCED
+	case own.lightBishops > 0 && own.darkBishops > 0,
+		bishops > 0 && own.knights > 0,
+		own.knights >= 3:
+		return MATING_FORCED
+	case own.knights == 2 && bishops == 0:
+		// The two knights can only mate a king that walks into it
+		return MATING_HELP
+	case own.knights == 1:
+		// The opponent needs a piece to block the escape of its king
+		if opp.pieces() > 0 {
+			return MATING_HELP
+		}
+	case bishops > 0:
+		// Bishops on one color can not cover the squares of the other, the
+		// king has to be blocked by a piece that can stand there
+		oppBlockers := opp.pieces() - opp.lightBishops
+		if own.darkBishops > 0 {
+			oppBlockers = opp.pieces() - opp.darkBishops
+		}
+		if oppBlockers > 0 {
+			return MATING_HELP
+		}
+	}
+	return MATING_NONE
+}
+
+// A dead position is one in which neither player can ever checkmate, such as
+// king and bishop against king. Blocked pawn chains are not recognized.
+func (b Board) IsDeadPosition() bool {
+	rules := b.Rules()
+	return rules.Mating(b, piece.WHITE) == MATING_NONE && rules.Mating(b, piece.BLACK) == MATING_NONE
+}
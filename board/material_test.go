@@ -0,0 +1,48 @@
+package board
+
+import (
+	"testing"
+
+	"github.com/Jesselli/tchess/fen"
+	"github.com/Jesselli/tchess/piece"
+)
+
+func TestMating(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		white Mating
+		black Mating
+		dead  bool
+	}{
+		{"bare kings", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", MATING_NONE, MATING_NONE, true},
+		{"bishop", "4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", MATING_NONE, MATING_NONE, true},
+		{"knight", "4k3/8/8/8/8/8/8/1N2K3 w - - 0 1", MATING_NONE, MATING_NONE, true},
+		{"bishops on one color", "4k3/8/7b/8/8/4B3/8/2B1K3 w - - 0 1", MATING_NONE, MATING_NONE, true},
+		{"bishops on both colors", "4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1", MATING_FORCED, MATING_NONE, false},
+		{"opposite bishops", "2b1k3/8/8/8/8/8/8/2B1K3 w - - 0 1", MATING_HELP, MATING_HELP, false},
+		{"two knights", "4k3/8/8/8/8/8/8/1N2K1N1 w - - 0 1", MATING_HELP, MATING_NONE, false},
+		{"bishop and knight", "4k3/8/8/8/8/8/8/2B1K1N1 w - - 0 1", MATING_FORCED, MATING_NONE, false},
+		{"knight against knight", "1n2k3/8/8/8/8/8/8/1N2K3 w - - 0 1", MATING_HELP, MATING_HELP, false},
+		{"knight against queen", "3qk3/8/8/8/8/8/8/1N2K3 w - - 0 1", MATING_HELP, MATING_FORCED, false},
+		{"bishop against pawn", "4k3/p7/8/8/8/8/8/2B1K3 w - - 0 1", MATING_HELP, MATING_FORCED, false},
+		{"rook", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", MATING_FORCED, MATING_NONE, false},
+	}
+
+	for _, tt := range tests {
+		pos, err := fen.Parse(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		b := Board{Pieces: pos.Pieces}
+		if got := b.Mating(piece.WHITE); got != tt.white {
+			t.Errorf("%s: white is %d, want %d", tt.name, got, tt.white)
+		}
+		if got := b.Mating(piece.BLACK); got != tt.black {
+			t.Errorf("%s: black is %d, want %d", tt.name, got, tt.black)
+		}
+		if got := b.IsDeadPosition(); got != tt.dead {
+			t.Errorf("%s: dead position is %v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package board
+
+import (
+	"github.com/Jesselli/tchess/fen"
+	"github.com/Jesselli/tchess/piece"
+)
+
+// Variant holds the rules in which a chess variant differs from standard
+// chess: the starting position, which moves are legal and how the game is won.
+// Variants embed Standard and override what they change.
+type Variant interface {
+	// Name as given to -variant and sent to engines as UCI_Variant
+	Name() string
+	// Starting position as a FEN record
+	StartFen() string
+	// Rules a FEN record of the variant has to follow
+	FenChecks() fen.Check
+	// Kings may not be left in check. Without royal kings there is no check
+	// and a king is taken like any other piece.
+	RoyalKings() bool
+	// Pieces a pawn may promote to
+	Promotions() []piece.Type
+	// Captured pieces go into the pocket of the capturer, who may drop them
+	// back on the board instead of moving
+	Drops() bool
+	// Checked after the standard rules allowed the move, e.g. to force captures
+	AllowMove(b Board, mv Move, color piece.Color) (ok bool, msg string)
+	// Returns the player who won by the rules of the variant and why, or 0 if
+	// nobody did. color is the player to move.
+	Winner(b Board, color piece.Color) (piece.Color, string)
+	// Classifies the material of the player, see Board.Mating
+	Mating(b Board, color piece.Color) Mating
+}
+
+// Standard is the rules of standard chess. A Board without a Variant plays by
+// them.
+type Standard struct{}
+
+func (Standard) Name() string {
+	return "standard"
+}
+
+func (Standard) StartFen() string {
+	return fen.DEFAULT
+}
+
+func (Standard) FenChecks() fen.Check {
+	return fen.CHECK_ALL
+}
+
+func (Standard) RoyalKings() bool {
+	return true
+}
+
+func (Standard) Promotions() []piece.Type {
+	return PromotionTypes
+}
+
+func (Standard) Drops() bool {
+	return false
+}
+
+func (Standard) AllowMove(b Board, mv Move, color piece.Color) (bool, string) {
+	return true, ""
+}
+
+func (Standard) Winner(b Board, color piece.Color) (piece.Color, string) {
+	return 0, ""
+}
+
+func (Standard) Mating(b Board, color piece.Color) Mating {
+	return b.Mating(color)
+}
+
+// Returns the rules the board is played by
+func (b Board) Rules() Variant {
+	if b.Variant == nil {
+		return Standard{}
+	}
+	return b.Variant
+}
@@ -0,0 +1,273 @@
+package clock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Jesselli/tchess/piece"
+)
+
+// Method decides what a player gets back for making a move
+type Method string
+
+const (
+	METHOD_FISCHER   Method = "fischer"   // The bonus is added after every move
+	METHOD_BRONSTEIN Method = "bronstein" // Time used is given back, up to the bonus
+	METHOD_DELAY     Method = "delay"     // The clock only starts once the bonus has passed
+	METHOD_HOURGLASS Method = "hourglass" // Time used by one player goes to the other
+	// Every move has to be made within the stage's time, e.g. 3 days
+	METHOD_CORRESPONDENCE Method = "corr"
+)
+
+var Methods = []Method{METHOD_FISCHER, METHOD_BRONSTEIN, METHOD_DELAY, METHOD_HOURGLASS, METHOD_CORRESPONDENCE}
+
+// Stage is one period of a time control, e.g. 40 moves in 90 minutes
+type Stage struct {
+	Moves int           // Moves to make before the next stage, 0 for the rest of the game
+	Time  time.Duration // Added to the clock when the stage starts
+	Bonus time.Duration // Increment or delay, depending on the Method
+}
+
+// Control is a time control. A control without stages is untimed.
+type Control struct {
+	Method Method
+	Stages []Stage
+}
+
+func (c Control) Untimed() bool {
+	return len(c.Stages) == 0
+}
+
+// Move is the clock log entry of a single move
+type Move struct {
+	Color     piece.Color   `json:"color"`
+	Used      time.Duration `json:"used"`      // Time spent on the move
+	Remaining time.Duration `json:"remaining"` // Time left after the move, including any bonus
+}
+
+// Clock is a chess clock for two players. Time is measured between the
+// timestamps handed to it, which should come from time.Now so that changes to
+// the wall clock do not affect the game.
+type Clock struct {
+	Control   Control
+	Log       []Move
+	remaining [2]time.Duration
+	moves     [2]int // Moves made by each player
+	stage     [2]int // Current stage of each player
+	active    piece.Color
+	running   bool
+	turnStart time.Time // When the active player's clock last started running
+}
+
+func New(control Control) *Clock {
+	c := Clock{Control: control, active: piece.WHITE}
+	if !control.Untimed() {
+		c.remaining[0] = control.Stages[0].Time
+		c.remaining[1] = control.Stages[0].Time
+	}
+	return &c
+}
+
+func idx(color piece.Color) int {
+	if color == piece.BLACK {
+		return 1
+	}
+	return 0
+}
+
+func (c *Clock) Running() bool {
+	return c.running
+}
+
+func (c *Clock) Active() piece.Color {
+	return c.active
+}
+
+// Starts the clock of the given player
+func (c *Clock) Start(active piece.Color, now time.Time) {
+	c.active = active
+	c.running = true
+	c.turnStart = now
+}
+
+// Stops the clock, charging the active player for the time used so far
+func (c *Clock) Stop(now time.Time) {
+	if !c.running {
+		return
+	}
+	c.charge(c.used(now))
+	c.running = false
+}
+
+// Time the active player has been thinking since their clock last started
+func (c *Clock) used(now time.Time) time.Duration {
+	if !c.running {
+		return 0
+	}
+	return now.Sub(c.turnStart)
+}
+
+// Time taken off the active player's clock for having used the given time
+func (c *Clock) cost(used time.Duration) time.Duration {
+	if c.Control.Method != METHOD_DELAY {
+		return used
+	}
+	delay := c.currentStage(c.active).Bonus
+	if used < delay {
+		return 0
+	}
+	return used - delay
+}
+
+func (c *Clock) charge(used time.Duration) {
+	c.remaining[idx(c.active)] -= c.cost(used)
+	if c.Control.Method == METHOD_HOURGLASS {
+		c.remaining[idx(c.active.Opposite())] += used
+	}
+}
+
+func (c *Clock) currentStage(color piece.Color) Stage {
+	return c.Control.Stages[c.stage[idx(color)]]
+}
+
+// Time left for the player at the given moment
+func (c *Clock) Remaining(color piece.Color, now time.Time) time.Duration {
+	if c.Control.Untimed() {
+		return 0
+	}
+
+	remaining := c.remaining[idx(color)]
+	used := c.used(now)
+	if color == c.active {
+		remaining -= c.cost(used)
+	} else if c.Control.Method == METHOD_HOURGLASS {
+		remaining += used
+	}
+	return remaining
+}
+
+// Returns true if the active player has run out of time
+func (c *Clock) Flagged(now time.Time) bool {
+	return !c.Control.Untimed() && c.Remaining(c.active, now) <= 0
+}
+
+// Ends the move of the active player and starts the clock of their opponent.
+// Returns the log entry of the move.
+func (c *Clock) Press(now time.Time) Move {
+	color := c.active
+	i := idx(color)
+	used := c.used(now)
+
+	if !c.Control.Untimed() {
+		c.charge(used)
+		stage := c.currentStage(color)
+		switch c.Control.Method {
+		case METHOD_FISCHER:
+			c.remaining[i] += stage.Bonus
+		case METHOD_BRONSTEIN:
+			c.remaining[i] += min(used, stage.Bonus)
+		case METHOD_CORRESPONDENCE:
+			c.remaining[i] = stage.Time
+		}
+
+		c.moves[i]++
+		if c.moves[i] == c.stageEnd(c.stage[i]) && c.stage[i] < len(c.Control.Stages)-1 {
+			c.stage[i]++
+			c.remaining[i] += c.Control.Stages[c.stage[i]].Time
+		}
+	}
+
+	mv := Move{Color: color, Used: used, Remaining: c.remaining[i]}
+	c.Log = append(c.Log, mv)
+	c.active = color.Opposite()
+	c.turnStart = now
+	return mv
+}
+
+// Number of moves after which the given stage is over, 0 if it never ends
+func (c *Clock) stageEnd(stage int) int {
+	if c.Control.Stages[stage].Moves == 0 {
+		return 0
+	}
+	end := 0
+	for _, st := range c.Control.Stages[:stage+1] {
+		end += st.Moves
+	}
+	return end
+}
+
+// State is a snapshot of a Clock that can be stored, e.g. as JSON, and turned
+// back into a running clock by Restore
+type State struct {
+	Control   string           `json:"control"`
+	Remaining [2]time.Duration `json:"remaining"` // White and black, when the snapshot was taken
+	Moves     [2]int           `json:"moves"`
+	Stage     [2]int           `json:"stage"`
+	Active    piece.Color      `json:"active"`
+	Running   bool             `json:"running"`
+	SavedAt   time.Time        `json:"saved_at"`
+	// Time the active player had thought about the move, so that a delay or a
+	// Bronstein bonus is not granted twice
+	Used time.Duration `json:"used,omitempty"`
+	Log  []Move        `json:"log,omitempty"`
+}
+
+// Takes a snapshot of the clock. The time used by the active player so far is
+// included in its remaining time.
+func (c *Clock) State(now time.Time) State {
+	return State{
+		Control:   c.Control.String(),
+		Remaining: [2]time.Duration{c.Remaining(piece.WHITE, now), c.Remaining(piece.BLACK, now)},
+		Moves:     c.moves,
+		Stage:     c.stage,
+		Active:    c.active,
+		Running:   c.running,
+		SavedAt:   now.Round(0), // Only the wall clock reading means anything later
+		Used:      c.used(now),
+		Log:       c.Log,
+	}
+}
+
+// Recreates a clock from a snapshot. A correspondence clock keeps running
+// while the snapshot is stored, other clocks continue from where they were.
+func Restore(st State, now time.Time) (*Clock, error) {
+	control, err := Parse(st.Control)
+	if err != nil {
+		return nil, err
+	}
+	if st.Active != piece.WHITE && st.Active != piece.BLACK {
+		return nil, fmt.Errorf("The saved clock has no active player")
+	}
+	for i := range st.Stage {
+		if st.Stage[i] < 0 || (!control.Untimed() && st.Stage[i] >= len(control.Stages)) {
+			return nil, fmt.Errorf("The saved clock is in stage %d of a %d stage time control", st.Stage[i]+1, len(control.Stages))
+		}
+	}
+
+	c := &Clock{
+		Control:   control,
+		Log:       st.Log,
+		remaining: st.Remaining,
+		moves:     st.Moves,
+		stage:     st.Stage,
+		active:    st.Active,
+		running:   st.Running,
+		turnStart: now,
+	}
+	if !st.Running || control.Untimed() {
+		return c, nil
+	}
+
+	// The move continues with the time already used, which Remaining has been
+	// charged for
+	c.remaining[idx(c.active)] += c.cost(st.Used)
+	if control.Method == METHOD_HOURGLASS {
+		c.remaining[idx(c.active.Opposite())] -= st.Used
+	}
+	c.turnStart = now.Add(-st.Used)
+	if control.Method == METHOD_CORRESPONDENCE {
+		// The wall clock is all that is left to tell how long we were gone
+		c.turnStart = st.SavedAt.Add(-st.Used)
+	}
+	return c, nil
+}
@@ -0,0 +1,211 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jesselli/tchess/piece"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		tc      string
+		want    string
+		wantErr bool
+	}{
+		{"15m|5s", "15m0s+5s", false},
+		{"5+3", "5m0s+3s", false},
+		{"90m", "1h30m0s", false},
+		{"40/90+30,G/30+30", "40/1h30m0s+30s,G/30m0s+30s", false},
+		{"40/90+30 then G/30", "40/1h30m0s+30s,G/30m0s", false},
+		{"delay:5+3", "delay:5m0s+3s", false},
+		{"bronstein:15m|10s", "bronstein:15m0s+10s", false},
+		{"hourglass:1", "hourglass:1m0s", false},
+		{"15m", "15m0s", false},
+		{"15m5s", "15m5s", false},
+		{"15m|", "", true},
+		{"15m|5s|1s", "", true},
+		{"", "", true},
+		{"0+5", "", true},
+		{"G/30,40/90", "", true},
+		{"x/90", "", true},
+		{"blitz:5+3", "", true},
+		{"hourglass:5+3", "", true},
+		{"inf", "", true},
+		{"nan+3", "", true},
+		{"5+Infinity", "", true},
+		{"1e300", "", true},
+		{"1e300d", "", true},
+		{"5+1e20", "", true},
+	}
+
+	for _, test := range tests {
+		control, err := Parse(test.tc)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error for '%s', got %s", test.tc, control)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %v", test.tc, err)
+		} else if control.String() != test.want {
+			t.Errorf("Parsed '%s' as %s, expected %s", test.tc, control, test.want)
+		}
+	}
+}
+
+// Plays moves taking the given number of seconds each, alternating players
+func playMoves(c *Clock, start time.Time, secs ...int) time.Time {
+	now := start
+	for _, s := range secs {
+		now = now.Add(time.Duration(s) * time.Second)
+		c.Press(now)
+	}
+	return now
+}
+
+func mustParse(t *testing.T, tc string) Control {
+	control, err := Parse(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return control
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		tc           string
+		secs         []int
+		white, black time.Duration
+	}{
+		{"1+2", []int{10, 1}, 52 * time.Second, 61 * time.Second},
+		{"bronstein:1+2", []int{10, 1}, 52 * time.Second, 60 * time.Second},
+		{"delay:1+2", []int{10, 1}, 52 * time.Second, 60 * time.Second},
+		{"hourglass:1", []int{10, 3}, 53 * time.Second, 67 * time.Second},
+	}
+
+	for _, test := range tests {
+		c := New(mustParse(t, test.tc))
+		start := time.Now()
+		c.Start(piece.WHITE, start)
+		now := playMoves(c, start, test.secs...)
+
+		white := c.Remaining(piece.WHITE, now)
+		black := c.Remaining(piece.BLACK, now)
+		if white != test.white || black != test.black {
+			t.Errorf("%s: expected %s and %s left, got %s and %s", test.tc, test.white, test.black, white, black)
+		}
+	}
+}
+
+func TestRunningClock(t *testing.T) {
+	c := New(mustParse(t, "hourglass:1"))
+	start := time.Now()
+	c.Start(piece.WHITE, start)
+
+	now := start.Add(20 * time.Second)
+	if c.Remaining(piece.WHITE, now) != 40*time.Second || c.Remaining(piece.BLACK, now) != 80*time.Second {
+		t.Errorf("Expected the running clock to move time to black")
+	}
+
+	if c.Flagged(start.Add(59 * time.Second)) {
+		t.Errorf("White should not have flagged yet")
+	}
+	if !c.Flagged(start.Add(60 * time.Second)) {
+		t.Errorf("White should have flagged")
+	}
+
+	c.Stop(now)
+	if c.Remaining(piece.WHITE, now.Add(time.Hour)) != 40*time.Second {
+		t.Errorf("A stopped clock should not run")
+	}
+}
+
+func TestStages(t *testing.T) {
+	c := New(mustParse(t, "2/10+1,G/5"))
+	start := time.Now()
+	c.Start(piece.WHITE, start)
+
+	// Both players make their two moves of the first stage
+	now := playMoves(c, start, 60, 30, 60, 30)
+	if got := c.Remaining(piece.WHITE, now); got != 13*time.Minute+2*time.Second {
+		t.Errorf("Expected white to get the second stage's time, got %s", got)
+	}
+	if got := c.Remaining(piece.BLACK, now); got != 14*time.Minute+2*time.Second {
+		t.Errorf("Expected black to get the second stage's time, got %s", got)
+	}
+
+	// The last stage has no increment
+	now = playMoves(c, now, 60)
+	if got := c.Remaining(piece.WHITE, now); got != 12*time.Minute+2*time.Second {
+		t.Errorf("Expected no increment in the last stage, got %s", got)
+	}
+
+	if len(c.Log) != 5 {
+		t.Fatalf("Expected 5 moves in the log, got %d", len(c.Log))
+	}
+	last := c.Log[4]
+	if last.Color != piece.WHITE || last.Used != time.Minute || last.Remaining != 12*time.Minute+2*time.Second {
+		t.Errorf("Unexpected log entry %+v", last)
+	}
+}
+
+func TestCorrespondence(t *testing.T) {
+	c := New(mustParse(t, "corr:3"))
+	start := time.Now()
+	c.Start(piece.WHITE, start)
+
+	// White takes two days, black's clock starts over with 3 days
+	now := playMoves(c, start, 2*24*3600)
+	if got := c.Remaining(piece.WHITE, now); got != 72*time.Hour {
+		t.Errorf("Expected the time per move to reset, got %s", got)
+	}
+
+	// The clock keeps running while it is stored
+	st := c.State(now)
+	later := now.Add(24 * time.Hour)
+	restored, err := Restore(st, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Remaining(piece.BLACK, later); got != 48*time.Hour {
+		t.Errorf("Expected a day to pass while stored, got %s left", got)
+	}
+
+	// Other clocks continue where they stopped
+	c = New(mustParse(t, "5+3"))
+	c.Start(piece.WHITE, start)
+	restored, err = Restore(c.State(start.Add(time.Minute)), later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Remaining(piece.WHITE, later); got != 4*time.Minute {
+		t.Errorf("Expected the clock to continue from 4 minutes, got %s", got)
+	}
+
+	// A delay that was used up before saving is not granted again
+	c = New(mustParse(t, "delay:5+3"))
+	c.Start(piece.WHITE, start)
+	restored, err = Restore(c.State(start.Add(10*time.Second)), later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Remaining(piece.WHITE, later.Add(time.Second)); got != 5*time.Minute-8*time.Second {
+		t.Errorf("Expected the delay to stay used, got %s left", got)
+	}
+	restored.Press(later.Add(time.Second))
+	if got := restored.Log[0].Used; got != 11*time.Second {
+		t.Errorf("Expected the move to have taken 11s, got %s", got)
+	}
+}
+
+func TestUntimed(t *testing.T) {
+	c := New(mustParse(t, "none"))
+	start := time.Now()
+	c.Start(piece.WHITE, start)
+	playMoves(c, start, 3600, 3600)
+	if c.Flagged(start.Add(24*time.Hour)) || !c.Control.Untimed() {
+		t.Errorf("An untimed clock should never flag")
+	}
+}
@@ -0,0 +1,200 @@
+package clock
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const FormatHelp = "Use 15m|5s, 5+3 (minutes+seconds), 40/90+30,G/30+30 for stages, " +
+	"optionally prefixed with delay:, bronstein: or hourglass:. corr:3 gives 3 days per move and none is untimed"
+
+const UNTIMED = "none"
+
+// Parses a time control. Accepted formats are
+//
+//	15m|5s              15 minutes with a 5 second increment
+//	5+3                 5 minutes with a 3 second increment
+//	40/90+30,G/30+30    90 minutes for 40 moves, then 30 minutes for the rest
+//	delay:5+3           5 minutes with a 3 second delay
+//	corr:3              3 days for every move
+//	none                untimed
+//
+// Stages may also be separated by "then". Times without a unit are minutes,
+// or days for correspondence, and bonuses without a unit are seconds. Any time
+// may be given in days with a d suffix. The method defaults to Fischer.
+func Parse(tc string) (Control, error) {
+	control := Control{Method: METHOD_FISCHER}
+	tc = strings.TrimSpace(tc)
+	if tc == UNTIMED {
+		return control, nil
+	}
+
+	if prefix, rest, found := strings.Cut(tc, ":"); found {
+		control.Method = Method(strings.ToLower(prefix))
+		if !isMethod(control.Method) {
+			return control, fmt.Errorf("Unknown timing method '%s'. Use fischer, bronstein, delay, hourglass or corr", prefix)
+		}
+		tc = rest
+	}
+
+	timeUnit := time.Minute
+	if control.Method == METHOD_CORRESPONDENCE {
+		timeUnit = day
+	}
+
+	if strings.Contains(tc, "|") {
+		stage, err := parseLegacy(tc, timeUnit)
+		if err != nil {
+			return control, err
+		}
+		control.Stages = []Stage{stage}
+	} else {
+		tc = strings.ReplaceAll(tc, " then ", ",")
+		for _, s := range strings.Split(tc, ",") {
+			stage, err := parseStage(strings.TrimSpace(s), timeUnit)
+			if err != nil {
+				return control, err
+			}
+			control.Stages = append(control.Stages, stage)
+		}
+	}
+
+	return control, validate(control)
+}
+
+func isMethod(m Method) bool {
+	for _, method := range Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses the original 15m|5s format
+func parseLegacy(tc string, timeUnit time.Duration) (Stage, error) {
+	var stage Stage
+	parts := strings.Split(tc, "|")
+	if len(parts) != 2 {
+		return stage, fmt.Errorf("Time control '%s' should have a single '|' between the time and the increment", tc)
+	}
+
+	var err error
+	stage.Time, err = parseAmount(parts[0], timeUnit)
+	if err != nil {
+		return stage, err
+	}
+	stage.Bonus, err = parseAmount(parts[1], time.Second)
+	return stage, err
+}
+
+// Parses [moves/]time[+bonus], where moves may be G for the rest of the game
+func parseStage(s string, timeUnit time.Duration) (Stage, error) {
+	var stage Stage
+	if s == "" {
+		return stage, fmt.Errorf("Empty time control stage")
+	}
+
+	if moves, rest, found := strings.Cut(s, "/"); found {
+		if moves != "G" && moves != "g" {
+			n, err := strconv.Atoi(moves)
+			if err != nil || n <= 0 {
+				return stage, fmt.Errorf("'%s' in stage '%s' should be a number of moves or G", moves, s)
+			}
+			stage.Moves = n
+		}
+		s = rest
+	}
+
+	timeStr, bonusStr, hasBonus := strings.Cut(s, "+")
+	var err error
+	stage.Time, err = parseAmount(timeStr, timeUnit)
+	if err != nil {
+		return stage, err
+	}
+	if hasBonus {
+		stage.Bonus, err = parseAmount(bonusStr, time.Second)
+	}
+	return stage, err
+}
+
+const day = 24 * time.Hour
+
+// Parses a Go duration such as 1m30s, a number of days such as 3d, or a plain
+// number of the given unit
+func parseAmount(s string, unit time.Duration) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return toDuration(s, n*float64(unit))
+	}
+	if n, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64); err == nil {
+		return toDuration(s, n*float64(day))
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a duration", s)
+	}
+	return d, nil
+}
+
+// Converts nanoseconds to a Duration. ParseFloat also reads inf and nan, and
+// numbers too large for a Duration.
+func toDuration(s string, ns float64) (time.Duration, error) {
+	if math.IsNaN(ns) || math.IsInf(ns, 0) {
+		return 0, fmt.Errorf("'%s' is not a duration", s)
+	}
+	if ns >= math.MaxInt64 || ns <= math.MinInt64 {
+		return 0, fmt.Errorf("'%s' is too long for a time control", s)
+	}
+	return time.Duration(ns), nil
+}
+
+func validate(control Control) error {
+	for i, stage := range control.Stages {
+		if stage.Time <= 0 && i == 0 {
+			return fmt.Errorf("The first stage of a time control needs a positive time")
+		}
+		if stage.Time < 0 || stage.Bonus < 0 {
+			return fmt.Errorf("Times in a time control can not be negative")
+		}
+		if stage.Moves == 0 && i != len(control.Stages)-1 {
+			return fmt.Errorf("Only the last stage of a time control can last for the rest of the game")
+		}
+		if stage.Bonus > 0 && control.Method == METHOD_HOURGLASS {
+			return fmt.Errorf("Hourglass time controls do not have a bonus")
+		}
+	}
+	if control.Method == METHOD_CORRESPONDENCE && (len(control.Stages) != 1 || control.Stages[0].Bonus > 0) {
+		return fmt.Errorf("Correspondence time controls only set the time per move, e.g. corr:3 for 3 days")
+	}
+	return nil
+}
+
+// Formats the control in the format accepted by Parse
+func (c Control) String() string {
+	if c.Untimed() {
+		return UNTIMED
+	}
+
+	stages := make([]string, len(c.Stages))
+	for i, stage := range c.Stages {
+		s := stage.Time.String()
+		if stage.Moves > 0 {
+			s = fmt.Sprintf("%d/%s", stage.Moves, s)
+		} else if len(c.Stages) > 1 {
+			s = "G/" + s
+		}
+		if stage.Bonus > 0 {
+			s += "+" + stage.Bonus.String()
+		}
+		stages[i] = s
+	}
+
+	tc := strings.Join(stages, ",")
+	if c.Method != METHOD_FISCHER && c.Method != "" {
+		tc = string(c.Method) + ":" + tc
+	}
+	return tc
+}
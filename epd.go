@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Jesselli/tchess/gamestate"
+	"github.com/Jesselli/tchess/search"
+	"github.com/Jesselli/tchess/suite"
+	"github.com/Jesselli/tchess/uci"
+)
+
+const (
+	epdUsage        = "Usage: tchess epd [flags] <file>\nRuns the EPD test suite in the file and reports which positions the engine solves."
+	epdEngineHelp   = "Name of UCI executable on PATH. If empty, the built-in search is used"
+	epdMoveTimeHelp = "Milliseconds the engine may think about each position"
+	epdDepthHelp    = "Plies the engine searches at most, 0 for no limit"
+)
+
+// Runs 'tchess epd', which tests an engine against an EPD test suite
+func runEPD(args []string) error {
+	flags := flag.NewFlagSet("epd", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), epdUsage)
+		flags.PrintDefaults()
+	}
+	var engineFlag = flags.String("engine", "", epdEngineHelp)
+	var moveTime = flags.Int("movetime", 1000, epdMoveTimeHelp)
+	var depth = flags.Int("depth", 0, epdDepthHelp)
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf(epdUsage)
+	}
+	if *moveTime <= 0 || *depth < 0 {
+		return fmt.Errorf("-movetime has to be positive and -depth can not be negative")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("Could not open the test suite. %w", err)
+	}
+	defer file.Close()
+
+	var engine gamestate.Engine
+	if *engineFlag == "" {
+		engine = &search.Engine{MoveTime: time.Duration(*moveTime) * time.Millisecond, Depth: *depth}
+	} else {
+		uciPipe, err := uci.CreatePipe(*engineFlag)
+		if err != nil {
+			return err
+		}
+		uciPipe.MoveTimeMs = *moveTime
+		uciPipe.Depth = *depth
+		uciPipe.Handshake()
+		engine = &uciPipe
+	}
+
+	summary, err := suite.Run(context.Background(), file, engine, os.Stdout)
+	fmt.Println(summary)
+	return err
+}
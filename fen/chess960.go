@@ -0,0 +1,54 @@
+package fen
+
+import "github.com/Jesselli/tchess/piece"
+
+const (
+	CHESS960_POSITIONS = 960
+	// Number of the standard starting position among the Chess960 positions
+	CHESS960_STANDARD = 518
+)
+
+// Knight files among the five squares left after placing the bishops and the
+// queen, in the order of the standard numbering
+var chess960Knights = [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+// Returns the Chess960 starting position with the given number, from 0 to 959,
+// numbered as in Scharnagl's scheme. Position 518 is the standard one.
+func Chess960(n int) Position {
+	var backRank [8]piece.Type
+	free := func() []int {
+		files := []int{}
+		for file, t := range backRank {
+			if t == piece.NONE {
+				files = append(files, file)
+			}
+		}
+		return files
+	}
+
+	backRank[2*(n%4)+1] = piece.BISHOP // Light square
+	n /= 4
+	backRank[2*(n%4)] = piece.BISHOP // Dark square
+	n /= 4
+	backRank[free()[n%6]] = piece.QUEEN
+	n /= 6
+	files := free()
+	for _, i := range chess960Knights[n%10] {
+		backRank[files[i]] = piece.KNIGHT
+	}
+	// The king stands between the rooks
+	files = free()
+	backRank[files[0]] = piece.ROOK
+	backRank[files[1]] = piece.KING
+	backRank[files[2]] = piece.ROOK
+
+	pos := Position{ActiveColor: piece.WHITE, EnPassantSq: -1, FullMoveCount: 1}
+	for file, t := range backRank {
+		pos.Pieces[file] = piece.Piece{Type: t, Color: piece.BLACK}
+		pos.Pieces[8+file] = piece.PAWN_B
+		pos.Pieces[48+file] = piece.PAWN_W
+		pos.Pieces[56+file] = piece.Piece{Type: t, Color: piece.WHITE}
+	}
+	pos.Castling = Castling{files[2], files[0], files[2], files[0]}
+	return pos
+}
@@ -0,0 +1,122 @@
+package fen
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EPD is an Extended Position Description: the first four fields of a FEN
+// record followed by operations such as "bm Nf3;" or "id \"test 1\";"
+type EPD struct {
+	Position   Position
+	Operations []Operation
+}
+
+type Operation struct {
+	Opcode   string
+	Operands []string // Quotes are removed from string operands
+}
+
+// Returns the operands of the first operation with the given opcode
+func (e EPD) Get(opcode string) ([]string, bool) {
+	for _, op := range e.Operations {
+		if op.Opcode == opcode {
+			return op.Operands, true
+		}
+	}
+	return nil, false
+}
+
+// Parses an EPD line. The hmvc and fmvn operations set the move counters.
+func ParseEPD(line string) (EPD, error) {
+	var epd EPD
+	fields := splitFields(line)
+	if len(fields) < 4 {
+		return epd, errorf(FIELD_RECORD, len(line), "Expected 4 fields before the operations, found %d", len(fields))
+	}
+
+	opsStart := len(line)
+	if len(fields) > 4 {
+		opsStart = fields[4].column
+	}
+	var err error
+	epd.Position, err = Parse(line[:opsStart])
+	if err != nil {
+		return epd, err
+	}
+
+	epd.Operations, err = parseOperations(line, opsStart)
+	if err != nil {
+		return epd, err
+	}
+
+	for _, counter := range []struct {
+		opcode string
+		value  *int
+		min    int
+		field  Field
+	}{
+		{"hmvc", &epd.Position.HalfMoveClock, 0, FIELD_HALFMOVE_CLOCK},
+		{"fmvn", &epd.Position.FullMoveCount, 1, FIELD_FULLMOVE_COUNT},
+	} {
+		operands, ok := epd.Get(counter.opcode)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.Join(operands, " "))
+		if err != nil || n < counter.min {
+			col := opsStart + strings.Index(line[opsStart:], counter.opcode)
+			return epd, errorf(counter.field, col, "%s should be a number of at least %d", counter.opcode, counter.min)
+		}
+		*counter.value = n
+	}
+	return epd, nil
+}
+
+// Splits "opcode operand...;" sequences, keeping quoted operands together
+func parseOperations(line string, start int) ([]Operation, error) {
+	ops := make([]Operation, 0)
+	var op Operation
+	i := start
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == ';':
+			if op.Opcode == "" {
+				return nil, errorf(FIELD_OPERATIONS, i, "Operation without an opcode")
+			}
+			ops = append(ops, op)
+			op = Operation{}
+			i++
+		case c == '"':
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return nil, errorf(FIELD_OPERATIONS, i, "Unterminated string")
+			}
+			if op.Opcode == "" {
+				return nil, errorf(FIELD_OPERATIONS, i, "Operation without an opcode")
+			}
+			op.Operands = append(op.Operands, line[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := strings.IndexAny(line[i:], " \t;")
+			if end < 0 {
+				end = len(line) - i
+			}
+			word := line[i : i+end]
+			if op.Opcode == "" {
+				op.Opcode = word
+			} else {
+				op.Operands = append(op.Operands, word)
+			}
+			i += end
+		}
+	}
+
+	if op.Opcode != "" {
+		return nil, errorf(FIELD_OPERATIONS, len(line), "Operation '%s' should end with ';'", op.Opcode)
+	}
+	return ops, nil
+}
@@ -0,0 +1,50 @@
+package fen
+
+import "fmt"
+
+// Field of a FEN record
+type Field int
+
+const (
+	FIELD_RECORD Field = iota // The record as a whole
+	FIELD_PLACEMENT
+	FIELD_ACTIVE_COLOR
+	FIELD_CASTLING
+	FIELD_EN_PASSANT
+	FIELD_HALFMOVE_CLOCK
+	FIELD_FULLMOVE_COUNT
+	FIELD_OPERATIONS // EPD operations
+)
+
+var fieldNames = map[Field]string{
+	FIELD_RECORD:         "record",
+	FIELD_PLACEMENT:      "piece placement",
+	FIELD_ACTIVE_COLOR:   "active color",
+	FIELD_CASTLING:       "castling rights",
+	FIELD_EN_PASSANT:     "en passant square",
+	FIELD_HALFMOVE_CLOCK: "halfmove clock",
+	FIELD_FULLMOVE_COUNT: "fullmove number",
+	FIELD_OPERATIONS:     "operations",
+}
+
+func (f Field) String() string {
+	return fieldNames[f]
+}
+
+// Error points to the part of a FEN record that is wrong
+type Error struct {
+	Field  Field
+	Column int // Index of the offending character in the record, -1 if unknown
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	if e.Column < 0 {
+		return fmt.Sprintf("Invalid FEN %s: %s", e.Field, e.Msg)
+	}
+	return fmt.Sprintf("Invalid FEN %s at column %d: %s", e.Field, e.Column+1, e.Msg)
+}
+
+func errorf(field Field, column int, format string, args ...any) *Error {
+	return &Error{Field: field, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
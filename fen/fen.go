@@ -0,0 +1,503 @@
+package fen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Jesselli/tchess/piece"
+)
+
+const (
+	DEFAULT = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	// A castling right that has been lost
+	NO_ROOK = -1
+)
+
+// Position is the content of a FEN record. Squares are numbered like the
+// board's, from a8 = 0 to h1 = 63.
+type Position struct {
+	Pieces        [64]piece.Piece
+	ActiveColor   piece.Color
+	Castling      Castling
+	EnPassantSq   int // -1 if no pawn can be taken en passant
+	HalfMoveClock int
+	FullMoveCount int
+	// Crazyhouse keeps the pieces in hand after the placement, e.g. [Nnp], and
+	// marks promoted pieces with a ~ (Q~). Pockets are counted by color and type.
+	HasPockets bool
+	Pockets    [3][piece.KING + 1]int
+	Promoted   [64]bool
+}
+
+// Castling holds the file (0 = a) of the rook each side may still castle
+// with, or NO_ROOK if the right has been lost
+type Castling struct {
+	WhiteShort int
+	WhiteLong  int
+	BlackShort int
+	BlackLong  int
+}
+
+var NoCastling = Castling{NO_ROOK, NO_ROOK, NO_ROOK, NO_ROOK}
+
+// Returns the file of the rook the player may castle with on the king's side
+// if short is true, otherwise on the queen's side
+func (c Castling) Rook(color piece.Color, short bool) int {
+	switch {
+	case color == piece.WHITE && short:
+		return c.WhiteShort
+	case color == piece.WHITE:
+		return c.WhiteLong
+	case short:
+		return c.BlackShort
+	default:
+		return c.BlackLong
+	}
+}
+
+func (c *Castling) SetRook(color piece.Color, short bool, file int) {
+	switch {
+	case color == piece.WHITE && short:
+		c.WhiteShort = file
+	case color == piece.WHITE:
+		c.WhiteLong = file
+	case short:
+		c.BlackShort = file
+	default:
+		c.BlackLong = file
+	}
+}
+
+type field struct {
+	text   string
+	column int // Index of the field in the record
+}
+
+// Splits the record on spaces and remembers where each field started
+func splitFields(record string) []field {
+	fields := make([]field, 0, 6)
+	start := -1
+	for i := 0; i <= len(record); i++ {
+		if i == len(record) || record[i] == ' ' {
+			if start >= 0 {
+				fields = append(fields, field{record[start:i], start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return fields
+}
+
+// Parses and validates a FEN record. The move counters may be left out, in
+// which case they default to 0 and 1. Castling rights may be given as KQkq,
+// in X-FEN where a letter names the file of an inner rook, or in Shredder-FEN
+// where every right is a file letter.
+func Parse(record string) (Position, error) {
+	return parse(record, CHECK_ALL)
+}
+
+// Check is a set of the rules Parse enforces beyond the syntax of a record.
+// Variants leave out the rules their positions break.
+type Check int
+
+const (
+	// Each player has one king and the player not to move is not in check
+	CHECK_KINGS Check = 1 << iota
+	// No pawn is on the first or last rank
+	CHECK_PAWN_RANKS
+	// No player has more than 8 pawns or 16 pieces
+	CHECK_MATERIAL
+	CHECK_ALL = CHECK_KINGS | CHECK_PAWN_RANKS | CHECK_MATERIAL
+)
+
+// Like Parse, but only enforces the given checks
+func ParseChecked(record string, checks Check) (Position, error) {
+	return parse(record, checks)
+}
+
+func parse(record string, checks Check) (Position, error) {
+	pos := Position{Castling: NoCastling, EnPassantSq: -1, FullMoveCount: 1}
+	fields := splitFields(record)
+	if len(fields) != 4 && len(fields) != 6 {
+		col := len(record)
+		if len(fields) > 6 {
+			col = fields[6].column
+		}
+		return pos, errorf(FIELD_RECORD, col, "Expected 6 fields separated by spaces, found %d", len(fields))
+	}
+
+	var err error
+	if err = parsePlacement(fields[0], &pos); err != nil {
+		return pos, err
+	}
+	if pos.ActiveColor, err = parseActiveColor(fields[1]); err != nil {
+		return pos, err
+	}
+	if pos.Castling, err = parseCastling(fields[2], pos.Pieces); err != nil {
+		return pos, err
+	}
+	if pos.EnPassantSq, err = parseEnPassant(fields[3], pos.Pieces, pos.ActiveColor); err != nil {
+		return pos, err
+	}
+	if len(fields) == 6 {
+		if pos.HalfMoveClock, err = parseCounter(fields[4], FIELD_HALFMOVE_CLOCK, 0); err != nil {
+			return pos, err
+		}
+		if pos.FullMoveCount, err = parseCounter(fields[5], FIELD_FULLMOVE_COUNT, 1); err != nil {
+			return pos, err
+		}
+	}
+
+	return pos, validate(pos, fields, checks)
+}
+
+func parsePlacement(f field, pos *Position) error {
+	if start := strings.IndexByte(f.text, '['); start >= 0 {
+		err := parsePockets(field{f.text[start:], f.column + start}, pos)
+		if err != nil {
+			return err
+		}
+		f.text = f.text[:start]
+	}
+
+	pieces := &pos.Pieces
+	rank, file := 0, 0
+	lastWasDigit := false
+	for i := 0; i < len(f.text); i++ {
+		c := f.text[i]
+		col := f.column + i
+		switch {
+		case c == '~':
+			if i == 0 || piece.FromFenChar[f.text[i-1]] == piece.EMPTYP {
+				return errorf(FIELD_PLACEMENT, col, "'~' has to follow a piece")
+			}
+			pos.Promoted[rank*8+file-1] = true
+			continue
+		case c == '/':
+			if file != 8 {
+				return errorf(FIELD_PLACEMENT, col, "Rank %d has %d squares instead of 8", 8-rank, file)
+			}
+			rank++
+			file = 0
+			if rank > 7 {
+				return errorf(FIELD_PLACEMENT, col, "There are more than 8 ranks")
+			}
+			lastWasDigit = false
+			continue
+		case c >= '1' && c <= '8':
+			if lastWasDigit {
+				return errorf(FIELD_PLACEMENT, col, "Two digits in a row, empty squares should be added up")
+			}
+			file += int(c - '0')
+			lastWasDigit = true
+		default:
+			p, ok := piece.FromFenChar[c]
+			if !ok {
+				return errorf(FIELD_PLACEMENT, col, "Unknown piece '%c'", c)
+			}
+			if file < 8 {
+				pieces[rank*8+file] = p
+			}
+			file++
+			lastWasDigit = false
+		}
+		if file > 8 {
+			return errorf(FIELD_PLACEMENT, col, "Rank %d has more than 8 squares", 8-rank)
+		}
+	}
+
+	end := f.column + len(f.text)
+	if rank != 7 {
+		return errorf(FIELD_PLACEMENT, end, "There are %d ranks instead of 8", rank+1)
+	}
+	if file != 8 {
+		return errorf(FIELD_PLACEMENT, end, "Rank 1 has %d squares instead of 8", file)
+	}
+	return nil
+}
+
+// Reads the pieces in hand of Crazyhouse, e.g. [QNnp]. Kings can not be held.
+func parsePockets(f field, pos *Position) error {
+	end := len(f.text) - 1
+	if f.text[end] != ']' {
+		return errorf(FIELD_PLACEMENT, f.column+end, "Expected the pockets to end with ']'")
+	}
+	for i := 1; i < end; i++ {
+		p, ok := piece.FromFenChar[f.text[i]]
+		if !ok || p.Type == piece.KING {
+			return errorf(FIELD_PLACEMENT, f.column+i, "Unknown piece '%c' in the pockets", f.text[i])
+		}
+		pos.Pockets[p.Color][p.Type]++
+	}
+	pos.HasPockets = true
+	return nil
+}
+
+func parseActiveColor(f field) (piece.Color, error) {
+	switch f.text {
+	case "w":
+		return piece.WHITE, nil
+	case "b":
+		return piece.BLACK, nil
+	}
+	return piece.WHITE, errorf(FIELD_ACTIVE_COLOR, f.column, "Expected w or b, found '%s'", f.text)
+}
+
+// Square of the given file on the back rank of the player
+func backRankSq(color piece.Color, file int) int {
+	if color == piece.WHITE {
+		return 56 + file
+	}
+	return file
+}
+
+// File of the player's king if it is on its first rank, otherwise -1
+func (pos Position) KingFile(color piece.Color) int {
+	return findKingFile(pos.Pieces, color)
+}
+
+func findKingFile(pieces [64]piece.Piece, color piece.Color) int {
+	for file := 0; file < 8; file++ {
+		p := pieces[backRankSq(color, file)]
+		if p.Type == piece.KING && p.Color == color {
+			return file
+		}
+	}
+	return -1
+}
+
+func parseCastling(f field, pieces [64]piece.Piece) (Castling, error) {
+	castling := NoCastling
+	if f.text == "-" {
+		return castling, nil
+	}
+
+	seen := make(map[byte]bool)
+	for i := 0; i < len(f.text); i++ {
+		c := f.text[i]
+		col := f.column + i
+		if seen[c] {
+			return castling, errorf(FIELD_CASTLING, col, "'%c' appears twice", c)
+		}
+		seen[c] = true
+
+		color := piece.WHITE
+		letter := c
+		if c >= 'a' && c <= 'z' {
+			color = piece.BLACK
+			letter = c - 'a' + 'A'
+		}
+		rook := piece.Piece{Type: piece.ROOK, Color: color}
+
+		kingFile := findKingFile(pieces, color)
+		if kingFile < 0 {
+			return castling, errorf(FIELD_CASTLING, col, "'%c' needs the %s king on its first rank", c, colorName(color))
+		}
+
+		var rookFile int
+		var short bool
+		switch {
+		case letter == 'K' || letter == 'Q':
+			// X-FEN: the outermost rook on that side of the king
+			short = letter == 'K'
+			rookFile = outermostRook(pieces, color, kingFile, short)
+			if rookFile < 0 {
+				return castling, errorf(FIELD_CASTLING, col, "'%c' needs a %s rook on the %s side of the king", c, colorName(color), sideName(short))
+			}
+		case letter >= 'A' && letter <= 'H':
+			// Shredder-FEN, or X-FEN for an inner rook
+			rookFile = int(letter - 'A')
+			if pieces[backRankSq(color, rookFile)] != rook {
+				return castling, errorf(FIELD_CASTLING, col, "'%c' needs a %s rook on %c%d", c, colorName(color), 'a'+rookFile, backRank(color))
+			}
+			if rookFile == kingFile {
+				return castling, errorf(FIELD_CASTLING, col, "'%c' names the file of the king", c)
+			}
+			short = rookFile > kingFile
+		default:
+			return castling, errorf(FIELD_CASTLING, col, "Unknown castling right '%c'", c)
+		}
+
+		if castling.Rook(color, short) != NO_ROOK {
+			return castling, errorf(FIELD_CASTLING, col, "%s can only castle %s side once", capitalize(colorName(color)), sideName(short))
+		}
+		castling.SetRook(color, short, rookFile)
+	}
+	return castling, nil
+}
+
+func outermostRook(pieces [64]piece.Piece, color piece.Color, kingFile int, short bool) int {
+	rook := piece.Piece{Type: piece.ROOK, Color: color}
+	if short {
+		for file := 7; file > kingFile; file-- {
+			if pieces[backRankSq(color, file)] == rook {
+				return file
+			}
+		}
+	} else {
+		for file := 0; file < kingFile; file++ {
+			if pieces[backRankSq(color, file)] == rook {
+				return file
+			}
+		}
+	}
+	return -1
+}
+
+func parseEnPassant(f field, pieces [64]piece.Piece, active piece.Color) (int, error) {
+	if f.text == "-" {
+		return -1, nil
+	}
+	if len(f.text) != 2 || f.text[0] < 'a' || f.text[0] > 'h' || f.text[1] < '1' || f.text[1] > '8' {
+		return -1, errorf(FIELD_EN_PASSANT, f.column, "Expected a square or -, found '%s'", f.text)
+	}
+
+	file := int(f.text[0] - 'a')
+	rank := int(f.text[1] - '0')
+	wantRank, pawnDir := 6, 1 // Black just moved a pawn two squares
+	if active == piece.BLACK {
+		wantRank, pawnDir = 3, -1
+	}
+	if rank != wantRank {
+		return -1, errorf(FIELD_EN_PASSANT, f.column+1, "The en passant square should be on rank %d when %s is to move", wantRank, colorName(active))
+	}
+
+	sq := (8-rank)*8 + file
+	pawn := piece.Piece{Type: piece.PAWN, Color: active.Opposite()}
+	if pieces[sq] != piece.EMPTYP || pieces[sq-pawnDir*8] != piece.EMPTYP || pieces[sq+pawnDir*8] != pawn {
+		return -1, errorf(FIELD_EN_PASSANT, f.column, "No pawn can have just moved past %s", f.text)
+	}
+	return sq, nil
+}
+
+func parseCounter(f field, fld Field, min int) (int, error) {
+	n, err := strconv.Atoi(f.text)
+	if err != nil || strings.HasPrefix(f.text, "+") {
+		return 0, errorf(fld, f.column, "Expected a number, found '%s'", f.text)
+	}
+	if n < min {
+		return 0, errorf(fld, f.column, "Should be at least %d", min)
+	}
+	return n, nil
+}
+
+func backRank(color piece.Color) int {
+	if color == piece.WHITE {
+		return 1
+	}
+	return 8
+}
+
+func colorName(color piece.Color) string {
+	if color == piece.WHITE {
+		return "white"
+	}
+	return "black"
+}
+
+func sideName(short bool) string {
+	if short {
+		return "king"
+	}
+	return "queen"
+}
+
+// Formats the position as a FEN record. Castling rights are written as KQkq
+// unless an inner rook is meant, which is written as its file (X-FEN).
+func Format(pos Position) string {
+	var sb strings.Builder
+
+	for rank := 0; rank < 8; rank++ {
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+		empty := 0
+		for file := 0; file < 8; file++ {
+			p := pos.Pieces[rank*8+file]
+			if p.Type == piece.NONE {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fmt.Fprintf(&sb, "%d", empty)
+				empty = 0
+			}
+			sb.WriteByte(piece.ToFenChar[p])
+			if pos.HasPockets && pos.Promoted[rank*8+file] {
+				sb.WriteByte('~')
+			}
+		}
+		if empty > 0 {
+			fmt.Fprintf(&sb, "%d", empty)
+		}
+	}
+	if pos.HasPockets {
+		sb.WriteString(formatPockets(pos))
+	}
+
+	if pos.ActiveColor == piece.BLACK {
+		sb.WriteString(" b ")
+	} else {
+		sb.WriteString(" w ")
+	}
+
+	castling := ""
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		castling += formatCastling(pos, color)
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	if pos.EnPassantSq >= 0 && pos.EnPassantSq < 64 {
+		fmt.Fprintf(&sb, " %c%d ", 'a'+pos.EnPassantSq%8, 8-pos.EnPassantSq/8)
+	} else {
+		sb.WriteString(" - ")
+	}
+
+	fmt.Fprintf(&sb, "%d %d", pos.HalfMoveClock, pos.FullMoveCount)
+	return sb.String()
+}
+
+// Order the pieces in hand are written in
+var pocketTypes = []piece.Type{piece.QUEEN, piece.ROOK, piece.BISHOP, piece.KNIGHT, piece.PAWN}
+
+func formatPockets(pos Position) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		for _, t := range pocketTypes {
+			letter := piece.ToFenChar[piece.Piece{Type: t, Color: color}]
+			sb.WriteString(strings.Repeat(string(letter), pos.Pockets[color][t]))
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func formatCastling(pos Position, color piece.Color) string {
+	s := ""
+	kingFile := findKingFile(pos.Pieces, color)
+	for _, short := range []bool{true, false} {
+		file := pos.Castling.Rook(color, short)
+		if file == NO_ROOK {
+			continue
+		}
+		letter := byte('Q')
+		if short {
+			letter = 'K'
+		}
+		if kingFile >= 0 && outermostRook(pos.Pieces, color, kingFile, short) != file {
+			letter = byte('A' + file)
+		}
+		if color == piece.BLACK {
+			letter = letter - 'A' + 'a'
+		}
+		s += string(letter)
+	}
+	return s
+}
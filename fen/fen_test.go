@@ -0,0 +1,144 @@
+package fen
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jesselli/tchess/piece"
+)
+
+func TestRoundTrip(t *testing.T) {
+	records := []string{
+		DEFAULT,
+		"rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+		"6k1/b7/8/8/5p2/7p/7P/7K w - - 0 54",
+		"r3k2r/8/8/8/8/8/8/R3K2R b Kq - 3 20",
+		// Chess960 with an inner rook written as its file
+		"4k3/8/8/8/8/8/8/RR2K2R w KB - 0 1",
+		// Crazyhouse with pockets and a promoted queen
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+		"r1bqkQ~1r/pppp1ppp/2n5/8/8/8/PPPP1PPP/RNB1KBNR[NPpp] b KQkq - 0 6",
+	}
+	for _, record := range records {
+		pos, err := Parse(record)
+		if err != nil {
+			t.Errorf("Could not parse %s: %v", record, err)
+		} else if got := Format(pos); got != record {
+			t.Errorf("Formatted %s as %s", record, got)
+		}
+	}
+}
+
+func TestCastlingNotations(t *testing.T) {
+	tests := []struct {
+		record string
+		want   string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"},
+		{"1r2k1r1/8/8/8/8/8/8/1R2K1R1 w GBgb - 0 1", "1r2k1r1/8/8/8/8/8/8/1R2K1R1 w KQkq - 0 1"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", DEFAULT},
+	}
+	for _, test := range tests {
+		pos, err := Parse(test.record)
+		if err != nil {
+			t.Errorf("Could not parse %s: %v", test.record, err)
+		} else if got := Format(pos); got != test.want {
+			t.Errorf("Formatted %s as %s, expected %s", test.record, got, test.want)
+		}
+	}
+
+	pos, _ := Parse("1r2k1r1/8/8/8/8/8/8/1R2K1R1 w GBgb - 0 1")
+	if pos.Castling.Rook(piece.WHITE, true) != 6 || pos.Castling.Rook(piece.BLACK, false) != 1 {
+		t.Errorf("Unexpected castling rooks %+v", pos.Castling)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		record string
+		field  Field
+		column int
+	}{
+		{"", FIELD_RECORD, 0},
+		{"8/8/8/8/8/8/8/8 w", FIELD_RECORD, 17},
+		{DEFAULT + " extra", FIELD_RECORD, 57},
+		{"rnbqkbnr/ppppxppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FIELD_PLACEMENT, 13},
+		{"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FIELD_PLACEMENT, 17},
+		{"rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FIELD_PLACEMENT, 16},
+		{"rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FIELD_PLACEMENT, 41},
+		{"rnbqkbnr/pppppppp/44/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FIELD_PLACEMENT, 19},
+		{"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1", FIELD_PLACEMENT, 0},
+		{"rnbqkbnP/pppppppp/8/8/8/8/PPPPPPP1/RNBQKBNR w KQq - 0 1", FIELD_PLACEMENT, 0},
+		{DEFAULT[:44] + "x KQkq - 0 1", FIELD_ACTIVE_COLOR, 44},
+		{"rnbqkbnr/ppppp1pp/8/5p1Q/4P3/8/PPPP1PPP/RNB1KBNR w KQkq - 0 1", FIELD_ACTIVE_COLOR, 49},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkqX - 0 1", FIELD_CASTLING, 50},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KKqk - 0 1", FIELD_CASTLING, 47},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w KQkq - 0 1", FIELD_CASTLING, 46},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w KQkq - 0 1", FIELD_CASTLING, 46},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w kq - 0 1", FIELD_PLACEMENT, 0},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e3 0 1", FIELD_EN_PASSANT, 52},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e4 0 1", FIELD_EN_PASSANT, 54},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1", FIELD_EN_PASSANT, 51},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", FIELD_HALFMOVE_CLOCK, 53},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", FIELD_FULLMOVE_COUNT, 55},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 one", FIELD_FULLMOVE_COUNT, 55},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.record)
+		var fenErr *Error
+		if !errors.As(err, &fenErr) {
+			t.Errorf("Expected a FEN error for '%s', got %v", test.record, err)
+		} else if fenErr.Field != test.field || fenErr.Column != test.column {
+			t.Errorf("Expected an error in the %s at column %d for '%s', got %v", test.field, test.column+1, test.record, err)
+		}
+	}
+}
+
+func TestParseEPD(t *testing.T) {
+	epd, err := ParseEPD(`r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - bm Bb5 Bc4; id "open game; 1"; hmvc 2; fmvn 3;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm, _ := epd.Get("bm"); len(bm) != 2 || bm[0] != "Bb5" || bm[1] != "Bc4" {
+		t.Errorf("Unexpected best moves %v", bm)
+	}
+	if id, _ := epd.Get("id"); len(id) != 1 || id[0] != "open game; 1" {
+		t.Errorf("Unexpected id %v", id)
+	}
+	if epd.Position.HalfMoveClock != 2 || epd.Position.FullMoveCount != 3 {
+		t.Errorf("Expected the counters from hmvc and fmvn, got %d and %d", epd.Position.HalfMoveClock, epd.Position.FullMoveCount)
+	}
+
+	for _, line := range []string{
+		"4k3/8/8/8/8/8/8/4K3 w -",
+		"4k3/8/8/8/8/8/8/4K3 w - - bm Kd2",
+		`4k3/8/8/8/8/8/8/4K3 w - - id "open;`,
+		"4k3/8/8/8/8/8/8/4K3 w - - hmvc x;",
+	} {
+		if _, err := ParseEPD(line); err == nil {
+			t.Errorf("Expected an error for %s", line)
+		}
+	}
+}
+
+func TestChess960(t *testing.T) {
+	if got := Format(Chess960(CHESS960_STANDARD)); got != DEFAULT {
+		t.Errorf("Position 518 is %s", got)
+	}
+	if got := Format(Chess960(0)); got != "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1" {
+		t.Errorf("Position 0 is %s", got)
+	}
+
+	seen := make(map[string]bool)
+	for n := 0; n < CHESS960_POSITIONS; n++ {
+		record := Format(Chess960(n))
+		if seen[record] {
+			t.Fatalf("Position %d repeats %s", n, record)
+		}
+		seen[record] = true
+		if _, err := Parse(record); err != nil {
+			t.Fatalf("Position %d is invalid: %v", n, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package fen
+
+import (
+	"github.com/Jesselli/tchess/piece"
+)
+
+// Checks that the parsed fields describe a position that can be reached
+func validate(pos Position, fields []field, checks Check) error {
+	placement := fields[0].column
+	var kings, pawns, counts [3]int
+	kingSq := [3]int{-1, -1, -1}
+	for sq, p := range pos.Pieces {
+		if p.Type == piece.NONE {
+			continue
+		}
+		counts[p.Color]++
+		switch p.Type {
+		case piece.KING:
+			kings[p.Color]++
+			kingSq[p.Color] = sq
+		case piece.PAWN:
+			pawns[p.Color]++
+			if checks&CHECK_PAWN_RANKS != 0 && (sq < 8 || sq >= 56) {
+				return errorf(FIELD_PLACEMENT, placement, "There is a pawn on the %s rank", backRankName(sq))
+			}
+		}
+	}
+
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		if checks&CHECK_KINGS != 0 && kings[color] != 1 {
+			return errorf(FIELD_PLACEMENT, placement, "%s has %d kings instead of 1", capitalize(colorName(color)), kings[color])
+		}
+		if checks&CHECK_MATERIAL == 0 {
+			continue
+		}
+		if pawns[color] > 8 {
+			return errorf(FIELD_PLACEMENT, placement, "%s has %d pawns", capitalize(colorName(color)), pawns[color])
+		}
+		if counts[color] > 16 {
+			return errorf(FIELD_PLACEMENT, placement, "%s has %d pieces", capitalize(colorName(color)), counts[color])
+		}
+	}
+
+	waiting := pos.ActiveColor.Opposite()
+	if checks&CHECK_KINGS != 0 && isAttacked(pos.Pieces, kingSq[waiting], pos.ActiveColor) {
+		return errorf(FIELD_ACTIVE_COLOR, fields[1].column, "%s is to move while the %s king is in check", capitalize(colorName(pos.ActiveColor)), colorName(waiting))
+	}
+	return nil
+}
+
+func backRankName(sq int) string {
+	if sq < 8 {
+		return "8th"
+	}
+	return "1st"
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
+
+// Returns true if a piece of the given color attacks the square
+func isAttacked(pieces [64]piece.Piece, sq int, by piece.Color) bool {
+	file, rank := sq%8, sq/8
+	onBoard := func(f, r int) bool {
+		return f >= 0 && f <= 7 && r >= 0 && r <= 7
+	}
+	at := func(f, r int) piece.Piece {
+		if !onBoard(f, r) {
+			return piece.EMPTYP
+		}
+		return pieces[r*8+f]
+	}
+	is := func(p piece.Piece, types ...piece.Type) bool {
+		if p.Color != by {
+			return false
+		}
+		for _, t := range types {
+			if p.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Pawns attack towards the other side, so a white pawn is found below
+	pawnRank := rank + 1
+	if by == piece.BLACK {
+		pawnRank = rank - 1
+	}
+	if is(at(file-1, pawnRank), piece.PAWN) || is(at(file+1, pawnRank), piece.PAWN) {
+		return true
+	}
+
+	for _, d := range [][2]int{{1, 2}, {2, 1}, {-1, 2}, {-2, 1}, {1, -2}, {2, -1}, {-1, -2}, {-2, -1}} {
+		if is(at(file+d[0], rank+d[1]), piece.KNIGHT) {
+			return true
+		}
+	}
+
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}} {
+		diagonal := d[0] != 0 && d[1] != 0
+		for dist := 1; onBoard(file+d[0]*dist, rank+d[1]*dist); dist++ {
+			p := at(file+d[0]*dist, rank+d[1]*dist)
+			if dist == 1 && is(p, piece.KING) {
+				return true
+			}
+			if p.Type == piece.NONE {
+				continue
+			}
+			if (diagonal && is(p, piece.BISHOP, piece.QUEEN)) || (!diagonal && is(p, piece.ROOK, piece.QUEEN)) {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
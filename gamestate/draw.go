@@ -0,0 +1,192 @@
+package gamestate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/piece"
+)
+
+// A player may claim a draw once the position repeats three times or fifty
+// moves pass without a capture or pawn move. At five repetitions or seventy-five
+// moves the game is drawn without a claim.
+const (
+	CLAIM_REPETITIONS = 3
+	AUTO_REPETITIONS  = 5
+	CLAIM_HALF_MOVES  = 100
+	AUTO_HALF_MOVES   = 150
+)
+
+// DrawJudge is an Engine that can answer draw offers. Engines that are not
+// DrawJudges decline every offer.
+type DrawJudge interface {
+	// Returns true if the side to move in the position accepts a draw
+	AcceptDraw(ctx context.Context, fen string) (bool, error)
+}
+
+// Positions are the same if the same pieces are on the same squares, the same
+// side is to move and the castling rights are the same. Unlike FIDE, en passant
+// rights are not compared. In Crazyhouse the pockets have to match too.
+type positionKey struct {
+	pieces       [64]piece.Piece
+	castleRights uint8
+	activeColor  piece.Color
+	pockets      [3][piece.KING + 1]int
+}
+
+func keyOf(b board.Board, color piece.Color) positionKey {
+	return positionKey{b.Pieces, b.CastleRights, color, b.Pockets}
+}
+
+// Counts how often the position occurred in the game, including now
+func (gs *GameState) Repetitions() int {
+	return gs.countPosition(keyOf(gs.Board, gs.ActiveColor))
+}
+
+func (gs *GameState) countPosition(key positionKey) int {
+	count := 0
+	if keyOf(gs.Board, gs.ActiveColor) == key {
+		count++
+	}
+	for i, b := range gs.BoardHistory {
+		color := gs.ActiveColor
+		if (len(gs.BoardHistory)-i)%2 == 1 {
+			color = color.Opposite()
+		}
+		if keyOf(b, color) == key {
+			count++
+		}
+	}
+	return count
+}
+
+func colorName(c piece.Color) string {
+	if c == piece.WHITE {
+		return "White"
+	}
+	return "Black"
+}
+
+// The active player offers a draw. The offer stands until the opponent accepts
+// or declines it, or makes a move.
+func (gs *GameState) OfferDraw() error {
+	if gs.Status != STATUS_PLAYING {
+		return fmt.Errorf("A draw can only be offered in a game in progress")
+	}
+	if gs.DrawOfferedBy == gs.ActiveColor {
+		return fmt.Errorf("You already offered a draw")
+	}
+	if gs.DrawOfferedBy != 0 {
+		// Offering back is accepting
+		return gs.AcceptDraw()
+	}
+	gs.DrawOfferedBy = gs.ActiveColor
+	gs.SetMessage(fmt.Sprintf("%s offers a draw. %s may 'accept' or 'decline' it.", colorName(gs.ActiveColor), colorName(gs.ActiveColor.Opposite())))
+	gs.autosave()
+	return nil
+}
+
+// The opponent of the player who offered a draw accepts it
+func (gs *GameState) AcceptDraw() error {
+	if gs.Status != STATUS_PLAYING || gs.DrawOfferedBy == 0 {
+		return fmt.Errorf("There is no draw offer to accept")
+	}
+	gs.DrawOfferedBy = 0
+	gs.SetStatus(STATUS_DRAW_AGREEMENT)
+	return nil
+}
+
+func (gs *GameState) DeclineDraw() error {
+	if gs.Status != STATUS_PLAYING || gs.DrawOfferedBy == 0 {
+		return fmt.Errorf("There is no draw offer to decline")
+	}
+	gs.SetMessage(fmt.Sprintf("%s declines the draw offer.", colorName(gs.DrawOfferedBy.Opposite())))
+	gs.DrawOfferedBy = 0
+	gs.autosave()
+	return nil
+}
+
+// The given player gives up the game
+func (gs *GameState) Resign(color piece.Color) error {
+	if gs.Status != STATUS_PLAYING && gs.Status != STATUS_PAUSED {
+		return fmt.Errorf("Only a game in progress can be resigned")
+	}
+	gs.DrawOfferedBy = 0
+	if color == piece.WHITE {
+		gs.SetStatus(STATUS_RESIGN_BLACK_WINS)
+	} else {
+		gs.SetStatus(STATUS_RESIGN_WHITE_WINS)
+	}
+	return nil
+}
+
+// The active player claims a draw by threefold repetition, the fifty-move rule
+// or because neither player can force a mate, as with two knights against a
+// king. With a move, the claim is about the position after it and the move is
+// only played if the claim is wrong.
+func (gs *GameState) ClaimDraw(notation string) error {
+	if gs.Status != STATUS_PLAYING {
+		return fmt.Errorf("A draw can only be claimed in a game in progress")
+	}
+
+	repetitions := gs.Repetitions()
+	halfMoves := gs.HalfMoveClock
+	var mv board.Move
+	if notation != "" {
+		var err error
+		mv, err = gs.FindMove(notation)
+		if err != nil {
+			return err
+		}
+		next := gs.Board
+		next.CapturedPieces = nil
+		next.UpdateBoardWithMove(mv)
+		next.UpdateCastleRightsWithMove(mv, gs.ActiveColor)
+		repetitions = gs.countPosition(keyOf(next, gs.ActiveColor.Opposite())) + 1
+		halfMoves++
+		if mv.Piece == piece.PAWN || mv.Capture {
+			halfMoves = 0
+		}
+	}
+
+	switch {
+	case repetitions >= CLAIM_REPETITIONS:
+		gs.DrawOfferedBy = 0
+		gs.SetStatus(STATUS_DRAW_REPETITION)
+		return nil
+	case halfMoves >= CLAIM_HALF_MOVES:
+		gs.DrawOfferedBy = 0
+		gs.SetStatus(STATUS_DRAW_FIFTY_MOVES)
+		return nil
+	case notation == "" && gs.noForcedMate():
+		gs.DrawOfferedBy = 0
+		gs.SetStatus(STATUS_DRAW_INSUFFICIENT)
+		return nil
+	}
+
+	err := fmt.Errorf("No draw to claim. The position occurred %d times and %d moves were made without a capture or pawn move", repetitions, halfMoves/2)
+	if notation != "" {
+		// A claim with a move is binding: the move stands
+		gs.UpdateStateAfterMove(mv)
+	}
+	return err
+}
+
+// Returns why the active player may claim a draw, or "" if they may not
+func (gs *GameState) claimableDraw() string {
+	switch {
+	case gs.Repetitions() >= CLAIM_REPETITIONS:
+		return "The position occurred three times."
+	case gs.HalfMoveClock >= CLAIM_HALF_MOVES:
+		return "Fifty moves were made without a capture or pawn move."
+	case gs.noForcedMate():
+		return "Neither player can force a mate."
+	}
+	return ""
+}
+
+func (gs *GameState) noForcedMate() bool {
+	rules := gs.Board.Rules()
+	return rules.Mating(gs.Board, piece.WHITE) < board.MATING_FORCED && rules.Mating(gs.Board, piece.BLACK) < board.MATING_FORCED
+}
@@ -1,38 +1,39 @@
 package gamestate
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
-	"sync"
 	"time"
 
 	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/clock"
+	"github.com/Jesselli/tchess/fen"
 	"github.com/Jesselli/tchess/parser"
 	"github.com/Jesselli/tchess/piece"
-	"github.com/Jesselli/tchess/tui"
 )
 
-var clockUpdateTicker = time.NewTicker(100 * time.Millisecond)
-
 type Status string
 type GameState struct {
-	Board                board.Board
-	ActiveColor          piece.Color
-	enPassantSq          int // Square that can be taken en passant
-	HalfMoveClock        int
-	FullMoveCount        int
-	Message              string
-	Status               Status
-	MoveHistory          []board.Move
-	WhiteTimeRemainingMs int // In milliseconds
-	BlackTimeRemainingMs int
-	Increment            int
-	WhiteIsHuman         bool
-	BlackIsHuman         bool
-	BoardHistory         []board.Board
-	DrawMutex            sync.Mutex
+	Board         board.Board
+	ActiveColor   piece.Color
+	enPassantSq   int // Square that can be taken en passant, as read from a FEN record
+	HalfMoveClock int
+	FullMoveCount int
+	Status        Status
+	MoveHistory   []board.Move
+	Clock         *clock.Clock
+	WhiteIsHuman  bool
+	BlackIsHuman  bool
+	BoardHistory  []board.Board
+	Reviewing     bool
+	ReviewPly     int    // Number of moves played in the reviewed position
+	startFen      string // Position the game was started from
+	EngineInfo    string
+	Renderer      Renderer
+	AutosavePath  string      // The game is saved here after every move, if set
+	AutoQueen     bool        // Pawns promote to queens unless the move says otherwise
+	DrawOfferedBy piece.Color // Player whose draw offer is open, 0 if there is none
+	Chess960      bool        // The game started from a Chess960 position
 }
 
 const (
@@ -42,11 +43,19 @@ const (
 	STATUS_CHECKMATE_BLACK_WINS Status = "Checkmate! Black wins."
 	STATUS_TIMEOUT_WHITE_WINS   Status = "White wins on time!"
 	STATUS_TIMEOUT_BLACK_WINS   Status = "Black wins on time!"
+	STATUS_RESIGN_WHITE_WINS    Status = "Black resigns. White wins."
+	STATUS_RESIGN_BLACK_WINS    Status = "White resigns. Black wins."
+	STATUS_VARIANT_WHITE_WINS   Status = "White wins!" // By the rules of the variant
+	STATUS_VARIANT_BLACK_WINS   Status = "Black wins!"
 	STATUS_DRAW_INSUFFICIENT    Status = "Draw! Insufficient material."
+	STATUS_DRAW_TIMEOUT         Status = "Draw! Time ran out, but the opponent can not checkmate."
 	STATUS_DRAW_STALEMATE       Status = "Draw! Stalemate."
-	STATUS_DRAW_REPETITION      Status = "Draw! Threefold repetition."
-	STATUS_DRAW_FIFTY_MOVES     Status = "Draw! Fifty move rule."
+	STATUS_DRAW_REPETITION      Status = "Draw! Threefold repetition claimed."
+	STATUS_DRAW_FIFTY_MOVES     Status = "Draw! Fifty move rule claimed."
+	STATUS_DRAW_FIVEFOLD        Status = "Draw! Fivefold repetition."
+	STATUS_DRAW_SEVENTY_FIVE    Status = "Draw! Seventy-five move rule."
 	STATUS_DRAW_AGREEMENT       Status = "Draw by agreement!"
+	STATUS_PAUSED               Status = "Paused. Type 'resume' to continue."
 	STATUS_QUIT                 Status = "Quitting..."
 )
 
@@ -55,152 +64,127 @@ func CreateDefault() *GameState {
 	gs.ActiveColor = piece.WHITE
 	gs.Board = board.CreateDefault()
 	gs.enPassantSq = -1
+	gs.FullMoveCount = 1
 	gs.WhiteIsHuman = true
 	gs.BlackIsHuman = true
 	gs.BoardHistory = make([]board.Board, 0)
 	gs.Status = STATUS_NOT_STARTED
+	gs.Clock = clock.New(clock.Control{})
+	gs.Renderer = nopRenderer{}
 	return &gs
 }
 
-func (gs *GameState) UpdateAndDrawClocks(boardRotated bool) {
-	for {
-		<-clockUpdateTicker.C
-		gs.DrawMutex.Lock()
-		if gs.WhiteTimeRemainingMs <= 0 {
-			gs.Status = STATUS_TIMEOUT_BLACK_WINS
-			gs.Draw()
-			break
-		} else if gs.BlackTimeRemainingMs <= 0 {
-			gs.Status = STATUS_TIMEOUT_WHITE_WINS
-			gs.Draw()
-			break
-		}
+// Ends the game once the flag of the active player falls
+func (gs *GameState) TickClock(now time.Time) {
+	if gs.Status != STATUS_PLAYING || gs.Clock.Control.Untimed() {
+		return
+	}
 
-		wFg := tui.GRAY
-		bFg := tui.GRAY
-		if gs.ActiveColor == piece.WHITE {
-			gs.WhiteTimeRemainingMs -= 100
-			wFg = tui.WHITE
-		} else {
-			gs.BlackTimeRemainingMs -= 100
-			bFg = tui.WHITE
-		}
-		wTimeMs := gs.WhiteTimeRemainingMs
-		wMin := wTimeMs / 1000 / 60
-		wSec := wTimeMs - (wMin * 60 * 1000)
-		wTimeMsg := fmt.Sprintf("%02d:%02d", int(wMin), int(wSec/1000))
-
-		bTimeMs := gs.BlackTimeRemainingMs
-		bMin := bTimeMs / 1000 / 60
-		bSec := bTimeMs - (bMin * 60 * 1000)
-		bTimeMsg := fmt.Sprintf("%02d:%02d", int(bMin), int(bSec/1000))
-
-		if boardRotated {
-			tui.DrawMsgBox(wTimeMsg, 22, 2, wFg, tui.BLACK, true)
-			tui.DrawMsgBox(bTimeMsg, 22, 7, bFg, tui.BLACK, true)
-		} else {
-			tui.DrawMsgBox(wTimeMsg, 22, 7, wFg, tui.BLACK, true)
-			tui.DrawMsgBox(bTimeMsg, 22, 2, bFg, tui.BLACK, true)
-		}
-		gs.DrawMutex.Unlock()
+	gs.notify(func(r Renderer) { r.ClockTick(gs) })
+	if !gs.Clock.Flagged(now) {
+		return
+	}
+	switch {
+	case gs.Board.Rules().Mating(gs.Board, gs.ActiveColor.Opposite()) == board.MATING_NONE:
+		// Nobody wins on time with pieces that can never mate
+		gs.SetStatus(STATUS_DRAW_TIMEOUT)
+	case gs.ActiveColor == piece.WHITE:
+		gs.SetStatus(STATUS_TIMEOUT_BLACK_WINS)
+	default:
+		gs.SetStatus(STATUS_TIMEOUT_WHITE_WINS)
 	}
 }
 
-func (gs *GameState) DrawCaptures(boardRotated bool) {
-	var wCapSb strings.Builder // Pieces white has captured
-	var bCapSb strings.Builder
-	for _, v := range gs.Board.CapturedPieces {
-		if v.Color == piece.WHITE {
-			fmt.Fprintf(&bCapSb, "%c", piece.PieceRunesFilled[v.Type])
-		} else {
-			fmt.Fprintf(&wCapSb, "%c", piece.PieceRunesFilled[v.Type])
-		}
-	}
+// Time left on the clock of the given player
+func (gs *GameState) TimeRemaining(color piece.Color) time.Duration {
+	return gs.Clock.Remaining(color, time.Now())
+}
 
-	wY := 5
-	bY := 4
-	if boardRotated {
-		wY = 4
-		bY = 5
+func (gs *GameState) ParseTimeControlFlag(tcFlag string) error {
+	control, err := clock.Parse(tcFlag)
+	if err != nil {
+		return err
 	}
-	tui.DrawMsgBox(wCapSb.String(), 22, wY, tui.WHITE, tui.BLACK, false)
-	tui.DrawMsgBox(bCapSb.String(), 22, bY, tui.WHITE, tui.BLACK, false)
+	gs.Clock = clock.New(control)
+	return nil
 }
 
-func (gs *GameState) DrawMoveHistory() {
-	var sb strings.Builder
-	moveNum := 1
-	numRows := 8
-	for i := 0; i < len(gs.MoveHistory); i += 2 {
-		if len(gs.MoveHistory) > numRows*2 && i < len(gs.MoveHistory)-numRows*2 {
-			moveNum++
-			continue
-		}
-		mv := gs.MoveHistory[i]
-		nextMv := board.Move{}
-		if len(gs.MoveHistory) > i+1 {
-			nextMv = gs.MoveHistory[i+1]
-		}
-
-		fmt.Fprint(&sb, fmt.Sprintf("%d. %s   %s\n", moveNum, mv.ToShortStr(), nextMv.ToShortStr()))
-		moveNum++
+func (gs *GameState) StartGame() {
+	if gs.startFen == "" {
+		gs.startFen = gs.ToFen()
 	}
-	tui.DrawMsgBox(sb.String(), 38, 1, tui.WHITE, tui.BLACK, false)
+	if !gs.Clock.Running() {
+		// A restored correspondence clock is already running
+		gs.Clock.Start(gs.ActiveColor, time.Now())
+	}
+	gs.SetStatus(STATUS_PLAYING)
+	// The game may be over before it starts when set up from a position
+	gs.UpdateStatus()
 }
 
-func (gs *GameState) ParseTimeControlFlag(tcFlag string) error {
-	var err error
-	var clock time.Duration
-	var increment time.Duration
-	tcSplit := strings.Split(tcFlag, "|")
-	clock, err = time.ParseDuration(tcSplit[0])
-	increment, err = time.ParseDuration(tcSplit[1])
-	clockMs := int(clock.Milliseconds())
-	incrementMs := int(increment.Milliseconds())
-	gs.WhiteTimeRemainingMs = clockMs
-	gs.BlackTimeRemainingMs = clockMs
-	gs.Increment = incrementMs
-	return err
+// Stops both clocks until the game is resumed
+func (gs *GameState) Pause() error {
+	if gs.Status != STATUS_PLAYING {
+		return fmt.Errorf("Only a game in progress can be paused")
+	}
+	gs.SetStatus(STATUS_PAUSED)
+	return nil
 }
 
-func (gs *GameState) StartGame() {
-	rotatedBoard := gs.BlackIsHuman && !gs.WhiteIsHuman
-	go gs.UpdateAndDrawClocks(rotatedBoard)
-	gs.Status = STATUS_PLAYING
+func (gs *GameState) Resume() error {
+	if gs.Status != STATUS_PAUSED {
+		return fmt.Errorf("The game is not paused")
+	}
+	gs.Clock.Start(gs.ActiveColor, time.Now())
+	gs.SetStatus(STATUS_PLAYING)
+	return nil
 }
 
 func (gs *GameState) ParseAndExecuteAlgebraicNotation(cmd string) error {
-	var err error
-	var wantedMove board.Move
-	wantedMove, err = parser.AlgebraicNotationToMove(cmd)
+	matchingMove, err := gs.FindMove(cmd)
 	if err == nil {
-		var matchingMove board.Move
-		matchingMove, err = gs.Board.FindMatchingMove(wantedMove, gs.ActiveColor)
-		if err == nil {
-			gs.UpdateStateAfterMove(matchingMove)
-		}
+		gs.UpdateStateAfterMove(matchingMove)
 	}
 
 	if err != nil {
-		gs.Message = err.Error()
+		gs.SetMessage(err.Error())
 	}
 
 	return err
 }
 
-func (gs *GameState) Draw() {
-	gs.DrawMutex.Lock()
-	defer gs.DrawMutex.Unlock()
+// Returns the legal move of the active player described by the notation,
+// without playing it
+func (gs *GameState) FindMove(notation string) (board.Move, error) {
+	wantedMove, err := parser.AlgebraicNotationToMove(notation)
+	if err != nil {
+		return board.Move{}, err
+	}
 
-	rotatedBoard := gs.BlackIsHuman && !gs.WhiteIsHuman
-	gs.Board.Display(os.Stdout, rotatedBoard)
-	gs.DrawCaptures(rotatedBoard)
-	gs.DrawMoveHistory()
+	mv, err := gs.Board.FindMatchingMove(wantedMove, gs.ActiveColor)
+	var promotion *board.PromotionRequiredError
+	if gs.AutoQueen && errors.As(err, &promotion) {
+		mv = promotion.Move
+		mv.Promote = piece.QUEEN
+		err = nil
+	}
+	return mv, err
+}
 
-	if gs.Status > STATUS_PLAYING {
-		clockUpdateTicker.Stop()
-		gs.Message = string(gs.Status)
+// Returns the position being reviewed, or the live game when not reviewing
+func (gs *GameState) Shown() *GameState {
+	if gs.Reviewing {
+		// StartReview made sure that the start position can be set up
+		if pos, err := gs.positionAt(gs.ReviewPly); err == nil {
+			return pos
+		}
 	}
+	return gs
+}
+
+// The board is shown from black's side when only black is played by a human
+func (gs *GameState) IsBoardRotated() bool {
+	return gs.BlackIsHuman && !gs.WhiteIsHuman
 }
 
 func (gs *GameState) ActivePlayerIsHuman() bool {
@@ -208,14 +192,6 @@ func (gs *GameState) ActivePlayerIsHuman() bool {
 		(gs.ActiveColor == piece.BLACK && gs.BlackIsHuman)
 }
 
-func (gs *GameState) AddIncrement() {
-	if gs.ActiveColor == piece.WHITE {
-		gs.WhiteTimeRemainingMs += gs.Increment
-	} else {
-		gs.BlackTimeRemainingMs += gs.Increment
-	}
-}
-
 func (gs *GameState) SwitchTurn() {
 	if gs.ActiveColor == piece.WHITE {
 		gs.ActiveColor = piece.BLACK
@@ -225,61 +201,50 @@ func (gs *GameState) SwitchTurn() {
 }
 
 func (gs *GameState) UpdateStatus() {
+	status := gs.Status
+	if winner, reason := gs.Board.Rules().Winner(gs.Board, gs.ActiveColor); winner != 0 {
+		gs.Board.CheckSq = -1
+		gs.SetMessage(reason)
+		if winner == piece.WHITE {
+			gs.SetStatus(STATUS_VARIANT_WHITE_WINS)
+		} else {
+			gs.SetStatus(STATUS_VARIANT_BLACK_WINS)
+		}
+		return
+	}
+
 	validMvs := gs.Board.AllValidMoves(gs.ActiveColor)
 	inCheck := gs.Board.IsInCheck(gs.ActiveColor)
+	gs.Board.CheckSq = -1
+	if inCheck {
+		gs.Board.CheckSq = gs.Board.FindKing(gs.ActiveColor)
+	}
+
 	if len(validMvs) == 0 && inCheck {
 		if gs.ActiveColor == piece.WHITE {
-			gs.Status = STATUS_CHECKMATE_BLACK_WINS
+			status = STATUS_CHECKMATE_BLACK_WINS
 		} else {
-			gs.Status = STATUS_CHECKMATE_WHITE_WINS
+			status = STATUS_CHECKMATE_WHITE_WINS
 		}
 	} else if len(validMvs) == 0 {
-		gs.Status = STATUS_DRAW_STALEMATE
-	} else if inCheck {
-	}
-
-	pCnt := gs.Board.PieceCounts()
-	if !inCheck && pCnt[piece.EMPTYP] == 62 {
-		// king vs. king
-		gs.Status = STATUS_DRAW_INSUFFICIENT
-	} else if !inCheck && pCnt[piece.EMPTYP] == 61 &&
-		(pCnt[piece.BSHP_W] == 1 || pCnt[piece.BSHP_B] == 1) {
-		// king & bishop vs. king
-		gs.Status = STATUS_DRAW_INSUFFICIENT
-	} else if !inCheck && pCnt[piece.EMPTYP] == 61 &&
-		(pCnt[piece.KNGT_W] == 1 || pCnt[piece.KNGT_B] == 1) {
-		// king & knight vs. king
-		gs.Status = STATUS_DRAW_INSUFFICIENT
-	} else if !inCheck && pCnt[piece.EMPTYP] == 60 &&
-		(pCnt[piece.BSHP_W] == 1 && pCnt[piece.BSHP_B] == 1) {
-		// king & bishop vs. king & bishop -- bishops on same sq color
-		var wBishopOnLight bool
-		var bBishopOnLight bool
-		for sq, p := range gs.Board.Pieces {
-			if p == piece.BSHP_W {
-				wBishopOnLight = board.SquareIsLight(sq)
-			} else if p == piece.BSHP_B {
-				bBishopOnLight = board.SquareIsLight(sq)
-			}
-		}
-		if wBishopOnLight == bBishopOnLight {
-			gs.Status = STATUS_DRAW_INSUFFICIENT
-		}
+		status = STATUS_DRAW_STALEMATE
 	}
 
-	if gs.HalfMoveClock == 50 {
-		gs.Status = STATUS_DRAW_FIFTY_MOVES
+	if gs.Board.IsDeadPosition() {
+		status = STATUS_DRAW_INSUFFICIENT
 	}
 
-	// Three-fold repetition
-	positionCount := make(map[[64]piece.Piece]int, 0)
-	for _, board := range gs.BoardHistory {
-		positionCount[board.Pieces]++
-		if positionCount[board.Pieces] >= 3 {
-			gs.Status = STATUS_DRAW_REPETITION
-			break
-		}
+	// Threefold repetition and the fifty-move rule have to be claimed, see
+	// ClaimDraw. A checkmate on the seventy-fifth move still counts.
+	mated := len(validMvs) == 0 && inCheck
+	if !mated && gs.HalfMoveClock >= AUTO_HALF_MOVES {
+		status = STATUS_DRAW_SEVENTY_FIVE
 	}
+	if gs.Repetitions() >= AUTO_REPETITIONS {
+		status = STATUS_DRAW_FIVEFOLD
+	}
+
+	gs.SetStatus(status)
 }
 
 func (gs *GameState) UpdateMoveCounts(mv board.Move, c piece.Color) {
@@ -296,176 +261,166 @@ func (gs *GameState) UpdateMoveCounts(mv board.Move, c piece.Color) {
 }
 
 func (gs *GameState) ToFen() string {
-	var sb strings.Builder
-
-	// Board state
-	emptyCount := 0
-	for i, p := range gs.Board.Pieces {
-		if i > 0 && i%8 == 0 {
-			if emptyCount > 0 {
-				fmt.Fprintf(&sb, "%d", emptyCount)
-				emptyCount = 0
-			}
-			fmt.Fprint(&sb, "/")
-		}
+	return fen.Format(gs.fenPosition())
+}
 
-		if p.Type == piece.NONE {
-			emptyCount++
-		} else {
-			if emptyCount > 0 {
-				fmt.Fprintf(&sb, "%d", emptyCount)
-				emptyCount = 0
+func (gs *GameState) fenPosition() fen.Position {
+	pos := fen.Position{
+		Pieces:        gs.Board.Pieces,
+		ActiveColor:   gs.ActiveColor,
+		Castling:      fen.NoCastling,
+		EnPassantSq:   gs.enPassantSq,
+		HalfMoveClock: gs.HalfMoveClock,
+		FullMoveCount: gs.FullMoveCount,
+		HasPockets:    gs.Board.Rules().Drops(),
+		Pockets:       gs.Board.Pockets,
+		Promoted:      gs.Board.Promoted,
+	}
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		for _, short := range []bool{true, false} {
+			if gs.Board.CastleRights&board.CastleRight(color, short) != 0 {
+				pos.Castling.SetRook(color, short, int(gs.Board.CastleRookFile(color, short)-'a'))
 			}
-			fmt.Fprintf(&sb, "%c", piece.ToFenChar[p])
 		}
 	}
-	if emptyCount > 0 {
-		fmt.Fprintf(&sb, "%d", emptyCount)
-	}
-
-	// Player turn
-	if gs.ActiveColor == piece.WHITE {
-		fmt.Fprintf(&sb, " %c ", 'w')
-	} else {
-		fmt.Fprintf(&sb, " %c ", 'b')
-	}
-
-	// Castle availability
-	if gs.Board.CastleRights&board.CASTLE_WHITE_SHORT == board.CASTLE_WHITE_SHORT {
-		fmt.Fprintf(&sb, "%c", 'K')
-	}
-	if gs.Board.CastleRights&board.CASTLE_WHITE_LONG == board.CASTLE_WHITE_LONG {
-		fmt.Fprintf(&sb, "%c", 'Q')
-	}
-	if gs.Board.CastleRights&board.CASTLE_BLACK_SHORT == board.CASTLE_BLACK_SHORT {
-		fmt.Fprintf(&sb, "%c", 'k')
-	}
-	if gs.Board.CastleRights&board.CASTLE_BLACK_LONG == board.CASTLE_BLACK_LONG {
-		fmt.Fprintf(&sb, "%c", 'q')
-	}
-	if gs.Board.CastleRights == 0 {
-		fmt.Fprintf(&sb, "%c", '-')
-	}
+	return pos
+}
 
-	// En passant sq
-	if gs.enPassantSq >= 0 && gs.enPassantSq < 64 {
-		fmt.Fprintf(&sb, " %s ", board.SqNumToStr(gs.enPassantSq))
-	} else {
-		fmt.Fprint(&sb, " - ")
+// Sets up the position of the FEN record. The GameState is left unchanged if
+// the record is invalid, and the error is a *fen.Error.
+func (gs *GameState) LoadFen(record string) error {
+	pos, err := gs.parseFen(record)
+	if err != nil {
+		return err
 	}
+	return gs.loadPosition(pos)
+}
 
-	// Half move clock
-	fmt.Fprintf(&sb, "%d ", gs.HalfMoveClock)
-
-	// Full move count
-	fmt.Fprintf(&sb, "%d", gs.FullMoveCount)
+// Positions of variants may break some of the rules of standard chess
+func (gs *GameState) parseFen(record string) (fen.Position, error) {
+	return fen.ParseChecked(record, gs.Board.Rules().FenChecks())
+}
 
-	return sb.String()
+// Abandons the current game and starts a new one of the variant from its
+// starting position. A nil variant is standard chess.
+func (gs *GameState) SetupVariant(v board.Variant) error {
+	gs.Board.Variant = v
+	return gs.Setup(gs.Board.Rules().StartFen())
 }
 
-func (gs *GameState) LoadFen(fen string) error {
-	// Board state
-	i := 0
-	boardIdx := 0
-	var b [64]piece.Piece
-	var c byte = fen[0]
-	for c != ' ' {
-		if c >= '1' && c <= '8' {
-			boardIdx += int(c-'1') + 1
-		} else if c == '/' {
-			// Do nothing. End of rank.
-		} else {
-			p := piece.FromFenChar[c]
-			b[boardIdx] = p
-			boardIdx++
+func (gs *GameState) loadPosition(pos fen.Position) error {
+	if !gs.Board.Rules().Drops() && (pos.HasPockets || pos.Promoted != [64]bool{}) {
+		return &fen.Error{Field: fen.FIELD_PLACEMENT, Column: -1, Msg: "Pockets and promoted pieces (~) are only used in Crazyhouse"}
+	}
+	gs.Board.Pieces = pos.Pieces
+	gs.Board.Pockets = pos.Pockets
+	gs.Board.Promoted = pos.Promoted
+	gs.Board.CastleRights = 0
+	for _, color := range []piece.Color{piece.WHITE, piece.BLACK} {
+		for _, short := range []bool{true, false} {
+			// Any rook on the first rank may castle, as in Chess960
+			if rookFile := pos.Castling.Rook(color, short); rookFile != fen.NO_ROOK {
+				gs.Board.SetCastleRook(color, short, byte('a'+rookFile))
+			}
 		}
-
-		i++
-		c = fen[i]
 	}
-	gs.Board.Pieces = b
+	gs.ActiveColor = pos.ActiveColor
+	gs.enPassantSq = pos.EnPassantSq
+	gs.HalfMoveClock = pos.HalfMoveClock
+	gs.FullMoveCount = pos.FullMoveCount
+	return nil
+}
 
-	// Active player
-	// Current character should be a space, so advance the index
-	i++
-	c = fen[i]
-	if c == 'w' {
-		gs.ActiveColor = piece.WHITE
-	} else if c == 'b' {
-		gs.ActiveColor = piece.BLACK
+// Abandons the current game and sets up a new one from the FEN record, with
+// fresh clocks. The game still has to be started.
+func (gs *GameState) Setup(record string) error {
+	pos, err := gs.parseFen(record)
+	if err != nil {
+		return err
 	}
+	return gs.setupPosition(pos)
+}
 
-	// Castling
-	// Next character should be a space, so advance the index twice
-	i += 2
-	var castleStatus uint8 = 0b0000
-	c = fen[i]
-	for c != ' ' {
-		switch c {
-		case '-':
-			break
-		case 'K':
-			castleStatus |= board.CASTLE_WHITE_SHORT
-		case 'Q':
-			castleStatus |= board.CASTLE_WHITE_LONG
-		case 'k':
-			castleStatus |= board.CASTLE_BLACK_SHORT
-		case 'q':
-			castleStatus |= board.CASTLE_BLACK_LONG
-		}
-		i++
-		c = fen[i]
+// Abandons the current game and sets up the Chess960 starting position with
+// the given number
+func (gs *GameState) SetupChess960(n int) error {
+	if n < 0 || n >= fen.CHESS960_POSITIONS {
+		return fmt.Errorf("Chess960 positions are numbered from 0 to %d", fen.CHESS960_POSITIONS-1)
 	}
-	gs.Board.CastleRights = castleStatus
-
-	// En passant square
-	i++
-	c = fen[i]
-	if c == '-' {
-		gs.enPassantSq = -1
-	} else {
-		file := fen[i]
-		rank := fen[i+1]
-		alphaNum := fmt.Sprintf("%c%c", file, rank)
-		gs.enPassantSq = board.StrToSqNum(alphaNum)
-		i++
+	err := gs.setupPosition(fen.Chess960(n))
+	if err != nil {
+		return err
 	}
+	gs.Chess960 = true
+	return nil
+}
 
-	// TODO: Use fields for the rest of parsing
-	fields := strings.Fields(fen)
-
-	var retErr error
-
-	// Half move clock
-	halfMoves, err := strconv.Atoi(fields[4])
+// Like Setup, but for an EPD line. Returns the parsed line so that its
+// operations can be used.
+func (gs *GameState) SetupEPD(line string) (fen.EPD, error) {
+	epd, err := fen.ParseEPD(line)
 	if err != nil {
-		retErr = fmt.Errorf("Error parsing half move clock: %w", err)
+		return epd, err
 	}
-	gs.HalfMoveClock = halfMoves
+	return epd, gs.setupPosition(epd.Position)
+}
 
-	// Full move count
-	fullMoves, err := strconv.Atoi(fields[5])
+func (gs *GameState) setupPosition(pos fen.Position) error {
+	err := gs.loadPosition(pos)
 	if err != nil {
-		retErr = fmt.Errorf("Error parsing full move count: %w", err)
+		return err
 	}
 
-	gs.FullMoveCount = fullMoves
-	return retErr
+	gs.Board.ClearLastMove()
+	gs.Board.ClearHighlights()
+	gs.startFen = gs.ToFen()
+	gs.MoveHistory = nil
+	gs.BoardHistory = make([]board.Board, 0)
+	gs.Board.CapturedPieces = nil
+	gs.Board.Checks = [3]int{}
+	gs.Clock = clock.New(gs.Clock.Control)
+	gs.Reviewing = false
+	gs.ReviewPly = 0
+	gs.EngineInfo = ""
+	gs.DrawOfferedBy = 0
+	gs.Chess960 = false
+	gs.Status = STATUS_NOT_STARTED
+	return nil
 }
 
 // Fully updates the GameState after executing the specified Move. This includes
 // updating the board, incrementing move counters, checking for win conditions,
 // and switching the player turn.
 func (gs *GameState) UpdateStateAfterMove(mv board.Move) {
+	// Highlights belong to the input on the live board, not to the history
+	gs.Board.ClearHighlights()
 	gs.BoardHistory = append(gs.BoardHistory, gs.Board)
 	gs.Board.UpdateBoardWithMove(mv)
-	gs.Board.HiglightSq = mv.TrgSqNum()
+	gs.Board.LastMoveSrcSq = mv.SrcSqNum()
+	if mv.Drop {
+		gs.Board.LastMoveSrcSq = -1
+	}
+	gs.Board.LastMoveTrgSq = mv.TrgSqNum()
 	gs.MoveHistory = append(gs.MoveHistory, mv)
 	gs.Board.UpdateCastleRightsWithMove(mv, gs.ActiveColor)
 
 	gs.UpdateMoveCounts(mv, gs.ActiveColor)
-	gs.AddIncrement()
+	// The board has no en passant captures, so a square read from a FEN
+	// record can only be taken on the move right after it
+	gs.enPassantSq = -1
+	if gs.Board.IsInCheck(gs.ActiveColor.Opposite()) {
+		gs.Board.Checks[gs.ActiveColor]++
+	}
+	if gs.DrawOfferedBy == gs.ActiveColor.Opposite() {
+		// Moving declines the opponent's offer
+		gs.DrawOfferedBy = 0
+	}
+	gs.Clock.Press(time.Now())
 	gs.SwitchTurn()
 	gs.UpdateStatus()
-	gs.Message = mv.ToStr()
+	gs.notify(func(r Renderer) { r.MoveMade(gs, mv) })
+	if reason := gs.claimableDraw(); reason != "" && gs.Status == STATUS_PLAYING && gs.ActivePlayerIsHuman() {
+		gs.SetMessage(reason + " Type 'claim' for a draw.")
+	}
+	gs.autosave()
 }
@@ -1,11 +1,18 @@
 package gamestate
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/fen"
 	"github.com/Jesselli/tchess/piece"
+	"github.com/Jesselli/tchess/variant"
 )
 
 func TestStalemate(t *testing.T) {
@@ -33,3 +40,712 @@ func TestPawnPromotion(t *testing.T) {
 		t.Fatalf(msg)
 	}
 }
+
+func TestReviewPositions(t *testing.T) {
+	gs := CreateDefault()
+	gs.startFen = gs.ToFen()
+	for _, mv := range []string{"f3", "e5", "g4", "Qh4"} {
+		if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+			t.Fatalf("Could not play %s: %s", mv, err)
+		}
+	}
+	if gs.Status != STATUS_CHECKMATE_BLACK_WINS {
+		t.Fatalf("Expected status: %s Actual status: %s", STATUS_CHECKMATE_BLACK_WINS, gs.Status)
+	}
+
+	gs.ReviewGotoMove(2, false)
+	expectedFen := "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2"
+	if actualFen := gs.Shown().ToFen(); actualFen != expectedFen {
+		t.Fatalf("Expected: %s, Actual: %s", expectedFen, actualFen)
+	}
+
+	gs.ReviewPrev()
+	if gs.ReviewPly != 2 {
+		t.Fatalf("Expected ply 2, Actual: %d", gs.ReviewPly)
+	}
+	gs.ReviewFirst()
+	gs.ReviewPrev()
+	if gs.ReviewPly != 0 {
+		t.Fatalf("Expected ply 0, Actual: %d", gs.ReviewPly)
+	}
+}
+
+func TestReviewFromPosition(t *testing.T) {
+	gs := CreateDefault()
+	gs.Setup("4k3/8/8/8/8/8/8/R3K3 b - - 0 20")
+	gs.StartGame()
+	for _, mv := range []string{"Kd7", "Ra7", "Kc6"} {
+		if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+			t.Fatalf("Could not play %s: %s", mv, err)
+		}
+	}
+	if num, black := gs.MoveNumber(0); num != 20 || !black {
+		t.Errorf("Expected the first move to be 20..., got %d, %v", num, black)
+	}
+	if num, black := gs.MoveNumber(1); num != 21 || black {
+		t.Errorf("Expected the second move to be 21., got %d, %v", num, black)
+	}
+
+	gs.Resign(piece.WHITE)
+	if err := gs.ReviewGotoMove(20, true); err != nil || gs.ReviewPly != 1 {
+		t.Errorf("goto 20... went to ply %d, %v", gs.ReviewPly, err)
+	}
+	if msg := gs.ReviewMessage(); msg != "Reviewing 20... Kd7 (1/3)" {
+		t.Errorf("Unexpected message %s", msg)
+	}
+	if err := gs.ReviewGotoMove(21, false); err != nil || gs.ReviewPly != 2 {
+		t.Errorf("goto 21 went to ply %d, %v", gs.ReviewPly, err)
+	}
+	if f := gs.Shown().ToFen(); f != "8/R2k4/8/8/8/8/8/4K3 b - - 2 21" {
+		t.Errorf("Unexpected position %s", f)
+	}
+	if err := gs.ReviewGotoMove(20, false); err == nil {
+		t.Errorf("Went to white's move 20, which was played before the game started")
+	}
+	if err := gs.ReviewGotoMove(22, false); err == nil {
+		t.Errorf("Went to move 22, which was not played")
+	}
+}
+
+func TestReviewVariant(t *testing.T) {
+	games := map[board.Variant][]string{
+		variant.Horde{}:      {"e5", "d5"},
+		variant.Crazyhouse{}: {"e4", "d5", "exd5"},
+	}
+	for v, moves := range games {
+		gs := CreateDefault()
+		gs.SetupVariant(v)
+		gs.StartGame()
+		for _, mv := range moves {
+			if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+				t.Fatalf("%s: could not play %s: %s", v.Name(), mv, err)
+			}
+		}
+		gs.Resign(gs.ActiveColor)
+
+		if err := gs.ReviewFirst(); err != nil {
+			t.Fatalf("%s: %v", v.Name(), err)
+		}
+		if got := gs.Shown().ToFen(); got != v.StartFen() {
+			t.Errorf("%s: reviewing the start showed %s", v.Name(), got)
+		}
+		gs.ReviewNext()
+		want := gs.Shown().ToFen()
+		if err := gs.BranchFromReview(); err != nil {
+			t.Fatalf("%s: %v", v.Name(), err)
+		}
+		if gs.Board.Variant != v || gs.ToFen() != want {
+			t.Errorf("%s: branched to %s by the rules of %s", v.Name(), gs.ToFen(), gs.Board.Rules().Name())
+		}
+	}
+}
+
+func TestCheckHighlight(t *testing.T) {
+	gs := CreateDefault()
+	for _, mv := range []string{"e4", "f5", "Qh5"} {
+		if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+			t.Fatalf("Could not play %s: %s", mv, err)
+		}
+	}
+	if gs.Board.CheckSq != board.StrToSqNum("e8") {
+		t.Fatalf("Expected check on e8, Actual: %d", gs.Board.CheckSq)
+	}
+	if gs.Board.LastMoveSrcSq != board.StrToSqNum("d1") || gs.Board.LastMoveTrgSq != board.StrToSqNum("h5") {
+		t.Fatalf("Expected last move d1-h5, Actual: %d-%d", gs.Board.LastMoveSrcSq, gs.Board.LastMoveTrgSq)
+	}
+}
+
+func TestRendererNotifications(t *testing.T) {
+	gs := CreateDefault()
+	rec := &Recorder{}
+	gs.Renderer = rec
+	gs.Status = STATUS_PLAYING
+	for _, mv := range []string{"f3", "e5", "Ke3", "g4", "Qh4"} {
+		gs.ParseAndExecuteAlgebraicNotation(mv)
+	}
+
+	moves := rec.Filter(EVENT_MOVE_MADE)
+	if len(moves) != 4 {
+		t.Fatalf("Expected 4 moves, Actual: %d", len(moves))
+	}
+	messages := rec.Filter(EVENT_MESSAGE)
+	if len(messages) != 1 || messages[0].Message != "Illegal move" {
+		t.Fatalf("Expected a single 'Illegal move' message, Actual: %+v", messages)
+	}
+	statuses := rec.Filter(EVENT_STATUS_CHANGED)
+	if len(statuses) != 1 || statuses[0].Status != STATUS_CHECKMATE_BLACK_WINS {
+		t.Fatalf("Expected checkmate to be reported, Actual: %+v", statuses)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corr.json")
+	gs := CreateDefault()
+	gs.ParseTimeControlFlag("corr:3")
+	gs.AutosavePath = path
+	gs.StartGame()
+	for _, mv := range []string{"e4", "e5", "Nf3"} {
+		gs.ParseAndExecuteAlgebraicNotation(mv)
+	}
+	gs.Pause()
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ToFen() != gs.ToFen() || len(loaded.MoveHistory) != 3 {
+		t.Errorf("Expected %s, loaded %s", gs.ToFen(), loaded.ToFen())
+	}
+	if loaded.Status != STATUS_PAUSED || loaded.Clock.Running() {
+		t.Errorf("Expected the loaded game to be paused, got %s", loaded.Status)
+	}
+
+	err = loaded.Resume()
+	if err != nil || loaded.Status != STATUS_PLAYING || !loaded.Clock.Running() {
+		t.Errorf("Expected the game to resume, got %v", err)
+	}
+
+	// Results that can not be replayed are kept
+	gs.Resume()
+	gs.BlackIsHuman = false
+	gs.SetStatus(STATUS_TIMEOUT_WHITE_WINS)
+	loaded, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Status != STATUS_TIMEOUT_WHITE_WINS || loaded.BlackIsHuman {
+		t.Errorf("Expected a timeout against an engine, got %s", loaded.Status)
+	}
+}
+
+func TestLoadVersions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"v0.json": `{"start_fen": "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			"moves": ["e2e4"], "paused": true,
+			"clock": {"control": "corr:72h0m0s", "remaining": [1, 2], "active": 2}}`,
+		"future.json": `{"version": 99}`,
+		"status.json": `{"version": 1, "status": "won_on_style"}`,
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+
+	gs, err := Load(filepath.Join(dir, "v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.Status != STATUS_PAUSED || len(gs.MoveHistory) != 1 || !gs.BlackIsHuman {
+		t.Errorf("Expected a paused correspondence game, got %s", gs.Status)
+	}
+
+	for _, name := range []string{"future.json", "status.json", "missing.json"} {
+		_, err = Load(filepath.Join(dir, name))
+		if err == nil {
+			t.Errorf("Expected loading %s to fail", name)
+		}
+	}
+}
+
+func TestLoadFenErrors(t *testing.T) {
+	gs := CreateDefault()
+	before := gs.ToFen()
+	for _, record := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNZ w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ~KBNR w KQkq - 0 1",
+	} {
+		err := gs.LoadFen(record)
+		var fenErr *fen.Error
+		if !errors.As(err, &fenErr) {
+			t.Errorf("Expected a FEN error for %s, got %v", record, err)
+		}
+	}
+	if gs.ToFen() != before {
+		t.Errorf("Invalid FEN records should not change the game, got %s", gs.ToFen())
+	}
+
+	err := gs.LoadFen("r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1")
+	if err != nil || gs.ToFen() != "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1" {
+		t.Errorf("Expected Shredder-FEN castling to load, got %v", err)
+	}
+	err = gs.LoadFen("1r2k1r1/8/8/8/8/8/8/1R2K1R1 w GBgb - 0 1")
+	if err != nil || gs.ToFen() != "1r2k1r1/8/8/8/8/8/8/1R2K1R1 w KQkq - 0 1" {
+		t.Errorf("Expected Chess960 castling to load, got %v", err)
+	}
+
+	// Variants only relax the rules their positions break
+	tests := []struct {
+		rules board.Variant
+		fen   string
+		ok    bool
+	}{
+		{variant.KingOfTheHill{}, "8/8/8/8/8/8/8/8 w - - 0 1", false},
+		{variant.ThreeCheck{}, "P3k3/8/8/8/8/8/8/4K3 w - - 0 1", false},
+		{variant.Crazyhouse{}, "4k3/8/8/8/8/8/8/8[] w - - 0 1", false},
+		{variant.Crazyhouse{}, "4k3/8/8/8/8/8/PPPPPPPP/P3K3[] w - - 0 1", false},
+		{variant.Crazyhouse{}, "4k3/8/8/8/8/PPPPPPPP/P7/4K3[] w - - 0 1", true},
+		{variant.Antichess{}, "8/8/8/8/8/8/8/K1K5 w - - 0 1", true},
+		{variant.Antichess{}, "P7/8/8/8/8/8/8/K7 w - - 0 1", false},
+		{variant.Horde{}, variant.Horde{}.StartFen(), true},
+	}
+	for _, tt := range tests {
+		gs := CreateDefault()
+		gs.Board.Variant = tt.rules
+		if err := gs.LoadFen(tt.fen); (err == nil) != tt.ok {
+			t.Errorf("%s: loading %s returned %v", tt.rules.Name(), tt.fen, err)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	gs := CreateDefault()
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("e4")
+
+	err := gs.Setup("7k/5Q2/6K1/8/8/8/8/8 b - - 12 40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gs.MoveHistory) != 0 || gs.Status != STATUS_NOT_STARTED || gs.ActiveColor != piece.BLACK {
+		t.Errorf("Expected a new game with black to move")
+	}
+	gs.StartGame()
+	if gs.Status != STATUS_DRAW_STALEMATE {
+		t.Errorf("Expected the game to end in stalemate right away, got %s", gs.Status)
+	}
+
+	_, err = gs.SetupEPD("4k3/8/8/8/8/8/8/4K2R w K - hmvc 7; fmvn 30;")
+	if err != nil || gs.ToFen() != "4k3/8/8/8/8/8/8/4K2R w K - 7 30" {
+		t.Errorf("Unexpected position %s, %v", gs.ToFen(), err)
+	}
+
+	// The en passant square is gone after the next move
+	gs.Setup("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3")
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("Nf3")
+	gs.ParseAndExecuteAlgebraicNotation("Nf6")
+	if got := gs.ToFen(); got != "rnbqkb1r/ppp1p1pp/5n2/3pPp2/8/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 4" {
+		t.Errorf("Unexpected position %s", got)
+	}
+}
+
+func TestAmbiguousMove(t *testing.T) {
+	gs := CreateDefault()
+	gs.LoadFen("r1bqkb1r/pppppppp/2n2n2/8/3P4/5N2/PPP1PPPP/RNBQKB1R w KQkq - 3 3")
+
+	_, err := gs.FindMove("Nd2")
+	var ambiguous *board.AmbiguousMoveError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Expected an ambiguous move, got %v", err)
+	}
+	if len(ambiguous.Candidates) != 2 || err.Error() != "Nf3-d2 or Nb1-d2? Enter the square of the piece to move" {
+		t.Errorf("Unexpected candidates %+v: %v", ambiguous.Candidates, err)
+	}
+
+	mv, err := gs.FindMove("Nbd2")
+	if err != nil || mv.SrcSqNum() != board.StrToSqNum("b1") {
+		t.Errorf("Nbd2 found %+v, %v", mv, err)
+	}
+}
+
+func TestPromotionChoice(t *testing.T) {
+	gs := CreateDefault()
+	gs.LoadFen("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+
+	_, err := gs.FindMove("b8")
+	var promotion *board.PromotionRequiredError
+	if !errors.As(err, &promotion) || promotion.Move.ToLAN() != "b7b8" {
+		t.Fatalf("Expected to be asked for the promotion piece, got %v", err)
+	}
+
+	mv, err := gs.FindMove("b7b8n")
+	if err != nil || mv.Promote != piece.KNIGHT {
+		t.Errorf("b7b8n found %+v, %v", mv, err)
+	}
+
+	mv.Promote = piece.KING
+	if ok, _ := gs.Board.ValidateMove(mv, piece.WHITE); ok {
+		t.Error("A pawn promoting to a king was valid")
+	}
+	mv.Promote = piece.PAWN
+	if ok, _ := gs.Board.ValidateMove(mv, piece.WHITE); ok {
+		t.Error("A pawn promoting to a pawn was valid")
+	}
+
+	promotions := 0
+	for _, mv := range gs.Board.AllValidMoves(piece.WHITE) {
+		if mv.IsPromotion() {
+			promotions++
+		}
+	}
+	if promotions != 4 {
+		t.Errorf("Found %d promotions instead of 4", promotions)
+	}
+
+	gs.AutoQueen = true
+	mv, err = gs.FindMove("b8+")
+	if err != nil || mv.Promote != piece.QUEEN {
+		t.Errorf("Auto-queen found %+v, %v", mv, err)
+	}
+}
+
+func TestMoveSAN(t *testing.T) {
+	defer func(l piece.Language) { piece.ActiveLanguage = l }(piece.ActiveLanguage)
+
+	gs := CreateDefault()
+	gs.LoadFen("r3k2r/1P6/8/8/8/5N2/8/RN2K2R w KQkq - 0 1")
+	moves := []string{"Nbd2", "Ke7", "bxa8=Q", "Rxa8", "O-O", "Ra1", "Rxa1", "Kf6", "Ra6+", "Ke7", "Ne4", "Kf7", "Rh6", "Kg7", "Rh1"}
+	for _, m := range moves {
+		if err := gs.ParseAndExecuteAlgebraicNotation(m); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+
+	want := []string{"Nbd2", "Ke7", "bxa8=Q", "Rxa8", "O-O", "Rxa1", "Rxa1", "Kf6", "Ra6+", "Ke7", "Ne4", "Kf7", "Rh6", "Kg7", "Rh1"}
+	for i := range want {
+		if got := gs.MoveSAN(i); got != want[i] {
+			t.Errorf("Move %d is %s, want %s", i+1, got, want[i])
+		}
+	}
+
+	gs.LoadFen("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	gs.ParseAndExecuteAlgebraicNotation("Ra8")
+	if got := gs.MoveSAN(len(gs.MoveHistory) - 1); got != "Ra8#" {
+		t.Errorf("Mate is %s", got)
+	}
+
+	piece.ActiveLanguage = piece.Languages["fr"]
+	if got := gs.MoveSAN(2); got != "bxa8=D" {
+		t.Errorf("French promotion is %s", got)
+	}
+	if got := gs.MoveSAN(0); got != "Cbd2" {
+		t.Errorf("French knight move is %s", got)
+	}
+
+	defer func() { piece.MoveFigurines = "" }()
+	piece.MoveFigurines = piece.GLYPHS_OUTLINED
+	if got := gs.MoveSAN(2); got != "bxa8=♕" {
+		t.Errorf("Figurine promotion is %s", got)
+	}
+	if got := gs.MoveSAN(0); got != "♘bd2" {
+		t.Errorf("Figurine knight move is %s", got)
+	}
+}
+
+func TestDrawOfferAndResign(t *testing.T) {
+	gs := CreateDefault()
+	gs.StartGame()
+	if err := gs.AcceptDraw(); err == nil {
+		t.Error("Accepted a draw nobody offered")
+	}
+	if err := gs.OfferDraw(); err != nil {
+		t.Fatal(err)
+	}
+	gs.ParseAndExecuteAlgebraicNotation("e4")
+	if gs.DrawOfferedBy != piece.WHITE {
+		t.Error("The offer was withdrawn by white's own move")
+	}
+	gs.ParseAndExecuteAlgebraicNotation("e5")
+	if gs.DrawOfferedBy != 0 {
+		t.Error("Black's move did not decline the offer")
+	}
+
+	gs.OfferDraw()
+	gs.ParseAndExecuteAlgebraicNotation("Nf3")
+	if err := gs.AcceptDraw(); err != nil || gs.Status != STATUS_DRAW_AGREEMENT {
+		t.Errorf("Expected a draw by agreement, got %s (%v)", gs.Status, err)
+	}
+
+	gs = CreateDefault()
+	gs.StartGame()
+	gs.OfferDraw()
+	path := filepath.Join(t.TempDir(), "game.json")
+	gs.Save(path)
+	loaded, err := Load(path)
+	if err != nil || loaded.DrawOfferedBy != piece.WHITE {
+		t.Errorf("The draw offer was not saved (%v)", err)
+	}
+
+	if err := gs.Resign(piece.WHITE); err != nil || gs.Status != STATUS_RESIGN_BLACK_WINS {
+		t.Errorf("Expected black to win, got %s (%v)", gs.Status, err)
+	}
+	gs.Save(path)
+	loaded, err = Load(path)
+	if err != nil || loaded.Status != STATUS_RESIGN_BLACK_WINS {
+		t.Errorf("The resignation was not saved (%v)", err)
+	}
+}
+
+func TestDrawClaims(t *testing.T) {
+	gs := CreateDefault()
+	gs.StartGame()
+	shuffle := []string{"Nf3", "Nf6", "Ng1", "Ng8"}
+	for _, m := range shuffle {
+		gs.ParseAndExecuteAlgebraicNotation(m)
+	}
+	if err := gs.ClaimDraw(""); err == nil {
+		t.Error("Claimed a draw after the position occurred twice")
+	}
+	// The claim can be made with the move that repeats the position
+	for _, m := range shuffle[:3] {
+		gs.ParseAndExecuteAlgebraicNotation(m)
+	}
+	if err := gs.ClaimDraw("Ng8"); err != nil || gs.Status != STATUS_DRAW_REPETITION {
+		t.Errorf("Expected a claimed repetition, got %s (%v)", gs.Status, err)
+	}
+
+	gs = CreateDefault()
+	gs.StartGame()
+	for i := 0; i < 4; i++ {
+		for _, m := range shuffle {
+			gs.ParseAndExecuteAlgebraicNotation(m)
+		}
+	}
+	if gs.Status != STATUS_DRAW_FIVEFOLD {
+		t.Errorf("Expected a fivefold repetition, got %s", gs.Status)
+	}
+
+	gs = CreateDefault()
+	gs.Setup("4k3/8/8/8/8/8/8/R3K3 w - - 99 80")
+	gs.StartGame()
+	if err := gs.ClaimDraw("Ra2"); err != nil || gs.Status != STATUS_DRAW_FIFTY_MOVES {
+		t.Errorf("Expected a fifty-move draw, got %s (%v)", gs.Status, err)
+	}
+
+	gs = CreateDefault()
+	gs.Setup("4k3/8/8/8/8/8/8/R3K3 w - - 149 80")
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("Ra2")
+	if gs.Status != STATUS_DRAW_SEVENTY_FIVE {
+		t.Errorf("Expected a seventy-five move draw, got %s", gs.Status)
+	}
+	gs.Setup("6k1/8/6K1/8/8/8/8/R7 w - - 149 80")
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("Ra8")
+	if gs.Status != STATUS_CHECKMATE_WHITE_WINS {
+		t.Errorf("Mate on the seventy-fifth move is %s", gs.Status)
+	}
+}
+
+func TestTimeoutAgainstBareKing(t *testing.T) {
+	tests := []struct {
+		fen  string
+		want Status
+	}{
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", STATUS_DRAW_TIMEOUT},
+		{"4k3/8/8/8/8/8/8/2NQK3 w - - 0 1", STATUS_DRAW_TIMEOUT},
+		{"3nk3/8/8/8/8/8/8/3QK3 w - - 0 1", STATUS_TIMEOUT_BLACK_WINS},
+		{"3qk3/8/8/8/8/8/8/4K3 b - - 0 1", STATUS_DRAW_TIMEOUT},
+	}
+	for _, tt := range tests {
+		gs := CreateDefault()
+		gs.ParseTimeControlFlag("1m|0s")
+		gs.Setup(tt.fen)
+		gs.StartGame()
+		gs.TickClock(time.Now().Add(2 * time.Minute))
+		if gs.Status != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.fen, gs.Status, tt.want)
+		}
+	}
+}
+
+func TestDeadPosition(t *testing.T) {
+	gs := CreateDefault()
+	gs.Setup("4k3/8/7b/8/8/4B3/3p4/2B1K3 w - - 0 1")
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("Kxd2")
+	if gs.Status != STATUS_DRAW_INSUFFICIENT {
+		t.Errorf("Bishops on one color are %s", gs.Status)
+	}
+
+	gs.Setup("4k3/8/8/8/8/8/8/1N2K1N1 w - - 0 1")
+	gs.StartGame()
+	if gs.Status != STATUS_PLAYING {
+		t.Fatalf("Two knights are %s", gs.Status)
+	}
+	if err := gs.ClaimDraw(""); err != nil || gs.Status != STATUS_DRAW_INSUFFICIENT {
+		t.Errorf("Two knights could not be claimed, got %s (%v)", gs.Status, err)
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		want string // Pieces after the move, "" if it is illegal
+	}{
+		{"long with the king next to the rook", "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", "O-O-O", "4k3/8/8/8/8/8/8/2KR4"},
+		{"king takes rook", "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", "b1a1", "4k3/8/8/8/8/8/8/2KR4"},
+		{"king step onto the target", "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", "b1c1", "4k3/8/8/8/8/8/8/R1K5"},
+		{"short with the rook on g", "4k3/8/8/8/8/8/8/5KR1 w K - 0 1", "O-O", "4k3/8/8/8/8/8/8/5RK1"},
+		{"king already on its target", "4k3/8/8/8/8/8/8/R1K5 w Q - 0 1", "O-O-O", "4k3/8/8/8/8/8/8/2KR4"},
+		{"through check", "4k3/8/8/8/8/8/2r5/RK6 w Q - 0 1", "O-O-O", ""},
+		{"rook path blocked", "4k3/8/8/8/8/8/8/RNK5 w Q - 0 1", "O-O-O", ""},
+		{"standard through check", "4k3/8/8/8/8/8/5r2/4K2R w K - 0 1", "O-O", ""},
+		{"standard", "4k3/8/8/8/8/8/8/4K2R w K - 0 1", "e1g1", "4k3/8/8/8/8/8/8/5RK1"},
+	}
+
+	for _, tt := range tests {
+		gs := CreateDefault()
+		if err := gs.Setup(tt.fen); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		gs.StartGame()
+		err := gs.ParseAndExecuteAlgebraicNotation(tt.move)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: %s was played", tt.name, tt.move)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := strings.Fields(gs.ToFen())[0]; got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	gs := CreateDefault()
+	if err := gs.SetupChess960(1000); err == nil {
+		t.Errorf("Position 1000 was set up")
+	}
+	gs.SetupChess960(0)
+	if !gs.Chess960 || !strings.HasPrefix(gs.ToFen(), "bbqnnrkr/") {
+		t.Errorf("Position 0 is %s", gs.ToFen())
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules board.Variant
+		fen   string
+		moves []string
+		want  Status
+	}{
+		{"king reaches the hill", variant.KingOfTheHill{}, "4k3/8/8/8/8/3K4/8/8 w - - 0 1", []string{"Kd4"}, STATUS_VARIANT_WHITE_WINS},
+		{"third check", variant.ThreeCheck{}, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", []string{"Ra8", "Ke7", "Ra7", "Ke6", "Ra6"}, STATUS_VARIANT_WHITE_WINS},
+		{"two checks", variant.ThreeCheck{}, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", []string{"Ra8", "Ke7", "Ra7"}, STATUS_PLAYING},
+		{"horde pawn steps twice", variant.Horde{}, "4k3/8/8/8/8/8/8/P7 w - - 0 1", []string{"a3"}, STATUS_PLAYING},
+		{"horde destroyed", variant.Horde{}, "4k3/8/8/8/8/8/1p6/P7 b - - 0 1", []string{"bxa1=Q"}, STATUS_VARIANT_BLACK_WINS},
+		{"antichess capture", variant.Antichess{}, variant.Antichess{}.StartFen(), []string{"e4", "d5", "exd5"}, STATUS_PLAYING},
+		{"antichess king promotion", variant.Antichess{}, "8/8/8/8/8/8/1p6/7R b - - 0 1", []string{"b1=K"}, STATUS_PLAYING},
+		{"antichess last piece lost", variant.Antichess{}, "8/8/8/8/8/8/1p6/2K5 w - - 0 1", []string{"Kxb2"}, STATUS_VARIANT_BLACK_WINS},
+	}
+
+	for _, tt := range tests {
+		gs := CreateDefault()
+		gs.Board.Variant = tt.rules
+		if err := gs.Setup(tt.fen); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		gs.StartGame()
+		for _, mv := range tt.moves {
+			if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+				t.Fatalf("%s: %s: %v", tt.name, mv, err)
+			}
+		}
+		if gs.Status != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, gs.Status, tt.want)
+		}
+	}
+
+	// Captures are compulsory in Antichess
+	gs := CreateDefault()
+	gs.SetupVariant(variant.Antichess{})
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("e4")
+	gs.ParseAndExecuteAlgebraicNotation("d5")
+	if err := gs.ParseAndExecuteAlgebraicNotation("Nf3"); err == nil {
+		t.Errorf("Nf3 was played while exd5 was possible")
+	}
+
+	// A standard FEN record needs both kings
+	gs = CreateDefault()
+	if err := gs.Setup(variant.Horde{}.StartFen()); err == nil {
+		t.Errorf("The horde was set up in standard chess")
+	}
+
+	path := filepath.Join(t.TempDir(), "horde.json")
+	gs.SetupVariant(variant.Horde{})
+	gs.StartGame()
+	gs.ParseAndExecuteAlgebraicNotation("a5")
+	gs.Save(path)
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Board.Variant != (variant.Horde{}) || loaded.ToFen() != gs.ToFen() {
+		t.Errorf("Loaded %s as %v", loaded.ToFen(), loaded.Board.Variant)
+	}
+}
+
+func TestCrazyhouse(t *testing.T) {
+	gs := CreateDefault()
+	gs.SetupVariant(variant.Crazyhouse{})
+	gs.StartGame()
+	for _, mv := range []string{"e4", "d5", "exd5", "Qxd5", "P@e6"} {
+		if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+			t.Fatalf("%s: %v", mv, err)
+		}
+	}
+	want := "rnb1kbnr/ppp1pppp/4P3/3q4/8/8/PPPP1PPP/RNBQKBNR[p] b KQkq - 0 3"
+	if gs.ToFen() != want {
+		t.Errorf("Got %s, want %s", gs.ToFen(), want)
+	}
+	path := filepath.Join(t.TempDir(), "crazyhouse.json")
+	gs.Save(path)
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ToFen() != want {
+		t.Errorf("Loaded %s, want %s", loaded.ToFen(), want)
+	}
+
+	tests := []struct {
+		name string
+		fen  string
+		move string
+	}{
+		{"pawn on the last rank", "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "P@a8"},
+		{"empty pocket", "4k3/8/8/8/8/8/8/4K3[n] w - - 0 1", "N@f3"},
+		{"occupied square", "4k3/8/8/8/8/8/8/4K3[N] w - - 0 1", "N@e8"},
+		{"king left in check", "4k3/8/8/8/8/8/8/r3K3[N] w - - 0 1", "N@f3"},
+	}
+	for _, tt := range tests {
+		gs := CreateDefault()
+		gs.Board.Variant = variant.Crazyhouse{}
+		if err := gs.Setup(tt.fen); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		gs.StartGame()
+		if err := gs.ParseAndExecuteAlgebraicNotation(tt.move); err == nil {
+			t.Errorf("%s: %s was played", tt.name, tt.move)
+		}
+	}
+
+	// A promoted piece goes back into the pocket as a pawn
+	gs = CreateDefault()
+	gs.Board.Variant = variant.Crazyhouse{}
+	gs.Setup("4k3/8/8/8/8/8/8/3q~K3[] w - - 0 1")
+	gs.StartGame()
+	if err := gs.ParseAndExecuteAlgebraicNotation("Kxd1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gs.ToFen(); got != "4k3/8/8/8/8/8/8/3K4[P] b - - 0 1" {
+		t.Errorf("Got %s after taking the promoted queen", got)
+	}
+
+	// Drops are only allowed in Crazyhouse
+	gs = CreateDefault()
+	gs.StartGame()
+	if err := gs.ParseAndExecuteAlgebraicNotation("N@f3"); err == nil {
+		t.Errorf("N@f3 was played in standard chess")
+	}
+}
@@ -0,0 +1,187 @@
+package gamestate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Engine picks the moves of a player that is not human
+type Engine interface {
+	// Returns the move to play in the given position in long algebraic
+	// notation. Should return early with ctx.Err() once ctx is cancelled.
+	BestMove(ctx context.Context, fen string) (string, error)
+}
+
+const CLOCK_TICK_INTERVAL = 100 * time.Millisecond
+
+type engineReply struct {
+	fen        string // Position the engine was asked about
+	move       string
+	acceptDraw bool // The engine accepts the draw offer instead of moving
+	err        error
+}
+
+// Loop runs a game. The goroutine calling Run owns the GameState: player input,
+// engine replies and clock ticks arrive on channels and are handled one at a
+// time. Other goroutines must not touch the GameState while the loop is
+// running, they hand their changes to Do instead.
+type Loop struct {
+	gs         *GameState
+	engine     Engine
+	actions    chan func(gs *GameState)
+	replies    chan engineReply
+	stopped    chan struct{} // Closed when Run returns
+	search     context.CancelFunc
+	searchDone chan struct{} // Closed when the running search has finished
+	searchFen  string
+
+	EngineName string
+	// Returns the text typed so far on the prompt. Called on the loop goroutine.
+	PromptText func() string
+}
+
+// Creates a loop for the game. engine may be nil if both players are human.
+func NewLoop(gs *GameState, engine Engine) *Loop {
+	return &Loop{
+		gs:         gs,
+		engine:     engine,
+		actions:    make(chan func(gs *GameState)),
+		replies:    make(chan engineReply),
+		stopped:    make(chan struct{}),
+		EngineName: "engine",
+		PromptText: func() string { return "" },
+	}
+}
+
+// Runs f on the loop goroutine and waits for it to finish. Returns false
+// without running f if the loop has already stopped.
+func (l *Loop) Do(f func(gs *GameState)) bool {
+	done := make(chan struct{})
+	action := func(gs *GameState) {
+		defer close(done)
+		f(gs)
+	}
+
+	select {
+	case l.actions <- action:
+	case <-l.stopped:
+		return false
+	}
+	<-done
+	return true
+}
+
+// Runs the game until a player quits or ctx is cancelled. Returns an error if
+// the engine fails.
+func (l *Loop) Run(ctx context.Context) error {
+	defer close(l.stopped)
+	defer l.stopSearch()
+
+	ticker := time.NewTicker(CLOCK_TICK_INTERVAL)
+	defer ticker.Stop()
+
+	redraw := true
+	for l.gs.Status != STATUS_QUIT {
+		l.startSearch(ctx)
+		if redraw {
+			l.gs.Redraw(l.PromptText())
+		}
+
+		redraw = true
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case f := <-l.actions:
+			f(l.gs)
+		case reply := <-l.replies:
+			err := l.playEngineMove(reply)
+			if err != nil {
+				return err
+			}
+		case now := <-ticker.C:
+			l.gs.TickClock(now)
+			// Ticks only redraw the clocks
+			redraw = false
+		}
+	}
+	return nil
+}
+
+// Asks the engine for a move if it is its turn and it is not already thinking.
+// A search for a position that is no longer on the board is abandoned.
+func (l *Loop) startSearch(ctx context.Context) {
+	gs := l.gs
+	engineToMove := l.engine != nil && gs.Status == STATUS_PLAYING && !gs.ActivePlayerIsHuman()
+	drawOffered := gs.DrawOfferedBy == gs.ActiveColor.Opposite()
+	fen := ""
+	if engineToMove {
+		fen = gs.ToFen()
+	}
+	if l.search != nil && (!engineToMove || l.searchFen != fen) {
+		l.stopSearch()
+	}
+	if !engineToMove || l.search != nil {
+		return
+	}
+
+	searchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	l.search = cancel
+	l.searchDone = done
+	l.searchFen = fen
+
+	go func() {
+		defer close(done)
+		var reply engineReply
+		judge, ok := l.engine.(DrawJudge)
+		if drawOffered && ok {
+			reply.acceptDraw, reply.err = judge.AcceptDraw(searchCtx, fen)
+		}
+		if !reply.acceptDraw && reply.err == nil {
+			reply.move, reply.err = l.engine.BestMove(searchCtx, fen)
+		}
+		reply.fen = fen
+		select {
+		case l.replies <- reply:
+		case <-searchCtx.Done():
+		}
+	}()
+}
+
+// Cancels the running search and waits for the engine to give up, so that the
+// next search does not talk to the engine at the same time
+func (l *Loop) stopSearch() {
+	if l.search == nil {
+		return
+	}
+	l.search()
+	<-l.searchDone
+	l.search = nil
+	l.searchDone = nil
+}
+
+func (l *Loop) playEngineMove(reply engineReply) error {
+	l.stopSearch()
+	if l.gs.Status != STATUS_PLAYING || reply.fen != l.gs.ToFen() {
+		return nil
+	}
+	if reply.err != nil {
+		return fmt.Errorf("%s failed to move. %w", l.EngineName, reply.err)
+	}
+	offered := l.gs.DrawOfferedBy == l.gs.ActiveColor.Opposite()
+	if reply.acceptDraw && offered {
+		l.gs.EngineInfo = fmt.Sprintf("%s accepts the draw", l.EngineName)
+		return l.gs.AcceptDraw()
+	}
+	if offered {
+		l.gs.SetMessage(fmt.Sprintf("%s declines the draw offer.", l.EngineName))
+	}
+
+	l.gs.EngineInfo = fmt.Sprintf("%s: bestmove %s", l.EngineName, reply.move)
+	err := l.gs.ParseAndExecuteAlgebraicNotation(reply.move)
+	if err != nil {
+		return fmt.Errorf("%s played '%s'. %w", l.EngineName, reply.move, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package gamestate
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Plays a fixed list of moves, one per call
+type scriptedEngine struct {
+	moves []string
+}
+
+func (e *scriptedEngine) BestMove(ctx context.Context, fen string) (string, error) {
+	mv := e.moves[0]
+	e.moves = e.moves[1:]
+	return mv, nil
+}
+
+// Polls the loop until cond holds for the game, then quits it
+func quitWhen(l *Loop, cond func(gs *GameState) bool) {
+	for {
+		done := false
+		ok := l.Do(func(gs *GameState) {
+			done = cond(gs)
+			if done {
+				gs.SetStatus(STATUS_QUIT)
+			}
+		})
+		if !ok || done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func runLoop(t *testing.T, l *Loop, cond func(gs *GameState) bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go quitWhen(l, cond)
+	err := l.Run(ctx)
+	if err != nil {
+		t.Fatalf("Loop stopped with %v", err)
+	}
+}
+
+func TestLoopEngineGame(t *testing.T) {
+	gs := CreateDefault()
+	gs.ParseTimeControlFlag("5m|0s")
+	gs.WhiteIsHuman = false
+	gs.BlackIsHuman = false
+	engine := &scriptedEngine{moves: []string{"f2f3", "e7e5", "g2g4", "d8h4"}}
+	l := NewLoop(gs, engine)
+	gs.StartGame()
+
+	runLoop(t, l, func(gs *GameState) bool { return gs.Status != STATUS_PLAYING })
+
+	if len(gs.MoveHistory) != 4 {
+		t.Errorf("Expected 4 moves, got %d", len(gs.MoveHistory))
+	}
+	if gs.Status != STATUS_QUIT {
+		t.Errorf("Expected the game to be quit, got %s", gs.Status)
+	}
+}
+
+func TestLoopHumanAgainstEngine(t *testing.T) {
+	gs := CreateDefault()
+	gs.ParseTimeControlFlag("5m|0s")
+	gs.BlackIsHuman = false
+	engine := &scriptedEngine{moves: []string{"e7e5"}}
+	l := NewLoop(gs, engine)
+	gs.StartGame()
+
+	go l.Do(func(gs *GameState) { gs.ParseAndExecuteAlgebraicNotation("e4") })
+	runLoop(t, l, func(gs *GameState) bool { return len(gs.MoveHistory) == 2 })
+
+	if gs.ToFen() != "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2" {
+		t.Errorf("Unexpected position %s", gs.ToFen())
+	}
+}
+
+func TestLoopTimeout(t *testing.T) {
+	gs := CreateDefault()
+	gs.ParseTimeControlFlag("150ms|0s")
+	rec := &Recorder{}
+	gs.Renderer = rec
+	l := NewLoop(gs, nil)
+	gs.StartGame()
+
+	var status Status
+	runLoop(t, l, func(gs *GameState) bool {
+		status = gs.Status
+		return gs.Status != STATUS_PLAYING
+	})
+
+	if status != STATUS_TIMEOUT_BLACK_WINS {
+		t.Errorf("Expected white to lose on time, got %s", status)
+	}
+	if len(rec.Filter(EVENT_CLOCK_TICK)) == 0 {
+		t.Errorf("Expected the clock to tick")
+	}
+}
+
+// Accepts every draw offer
+type agreeableEngine struct {
+	scriptedEngine
+}
+
+func (e *agreeableEngine) AcceptDraw(ctx context.Context, fen string) (bool, error) {
+	return true, nil
+}
+
+func TestLoopEngineAcceptsDraw(t *testing.T) {
+	gs := CreateDefault()
+	gs.ParseTimeControlFlag("5m|0s")
+	gs.BlackIsHuman = false
+	l := NewLoop(gs, &agreeableEngine{})
+	gs.StartGame()
+
+	go l.Do(func(gs *GameState) {
+		gs.OfferDraw()
+		gs.ParseAndExecuteAlgebraicNotation("e4")
+	})
+	runLoop(t, l, func(gs *GameState) bool { return gs.Status != STATUS_PLAYING })
+
+	if len(gs.MoveHistory) != 1 || gs.EngineInfo != "engine accepts the draw" {
+		t.Errorf("Expected the engine to accept instead of moving, got %d moves and '%s'", len(gs.MoveHistory), gs.EngineInfo)
+	}
+}
@@ -0,0 +1,218 @@
+package gamestate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Jesselli/tchess/clock"
+	"github.com/Jesselli/tchess/piece"
+	"github.com/Jesselli/tchess/variant"
+)
+
+// Version of the save file format. Bump it whenever a field changes meaning,
+// and teach Load to read the older versions.
+//
+//	0  Correspondence games: start_fen, moves, paused and clock only
+//	1  Adds the version, current fen, players, time control and status
+const SAVE_VERSION = 1
+
+// SavedGame is the JSON form of a GameState. The position is rebuilt by
+// replaying the moves from StartFen, Fen is there for other tools.
+type SavedGame struct {
+	Version      int         `json:"version"`
+	Fen          string      `json:"fen"`
+	StartFen     string      `json:"start_fen"`
+	Moves        []string    `json:"moves"` // Long algebraic notation
+	WhiteIsHuman bool        `json:"white_is_human"`
+	BlackIsHuman bool        `json:"black_is_human"`
+	TimeControl  string      `json:"time_control"`
+	Clock        clock.State `json:"clock"`
+	Status       string      `json:"status"`
+	DrawOffer    string      `json:"draw_offer,omitempty"` // "white" or "black" while an offer is open
+	Chess960     bool        `json:"chess960,omitempty"`
+	Variant      string      `json:"variant,omitempty"` // Empty for standard chess
+	Paused       bool        `json:"paused,omitempty"`  // Only used by version 0
+}
+
+// Names the statuses are saved as, so that the messages can change
+var statusNames = map[Status]string{
+	STATUS_NOT_STARTED:          "not_started",
+	STATUS_PLAYING:              "playing",
+	STATUS_CHECKMATE_WHITE_WINS: "checkmate_white_wins",
+	STATUS_CHECKMATE_BLACK_WINS: "checkmate_black_wins",
+	STATUS_TIMEOUT_WHITE_WINS:   "timeout_white_wins",
+	STATUS_TIMEOUT_BLACK_WINS:   "timeout_black_wins",
+	STATUS_RESIGN_WHITE_WINS:    "resign_white_wins",
+	STATUS_RESIGN_BLACK_WINS:    "resign_black_wins",
+	STATUS_VARIANT_WHITE_WINS:   "variant_white_wins",
+	STATUS_VARIANT_BLACK_WINS:   "variant_black_wins",
+	STATUS_DRAW_INSUFFICIENT:    "draw_insufficient",
+	STATUS_DRAW_TIMEOUT:         "draw_timeout",
+	STATUS_DRAW_STALEMATE:       "draw_stalemate",
+	STATUS_DRAW_REPETITION:      "draw_repetition",
+	STATUS_DRAW_FIFTY_MOVES:     "draw_fifty_moves",
+	STATUS_DRAW_FIVEFOLD:        "draw_fivefold",
+	STATUS_DRAW_SEVENTY_FIVE:    "draw_seventy_five_moves",
+	STATUS_DRAW_AGREEMENT:       "draw_agreement",
+	STATUS_PAUSED:               "paused",
+}
+
+var drawOfferNames = map[piece.Color]string{
+	piece.WHITE: "white",
+	piece.BLACK: "black",
+}
+
+func statusFromName(name string) (Status, bool) {
+	for status, n := range statusNames {
+		if n == name {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// Writes the game to the AutosavePath, if there is one
+func (gs *GameState) autosave() {
+	if gs.AutosavePath == "" {
+		return
+	}
+	err := gs.Save(gs.AutosavePath)
+	if err != nil {
+		gs.SetMessage(err.Error())
+	}
+}
+
+func (gs *GameState) Save(path string) error {
+	status := gs.Status
+	if status == STATUS_QUIT {
+		status = STATUS_PLAYING
+	}
+
+	saved := SavedGame{
+		Version:      SAVE_VERSION,
+		Fen:          gs.ToFen(),
+		StartFen:     gs.startFen,
+		Moves:        make([]string, len(gs.MoveHistory)),
+		WhiteIsHuman: gs.WhiteIsHuman,
+		BlackIsHuman: gs.BlackIsHuman,
+		TimeControl:  gs.Clock.Control.String(),
+		Clock:        gs.Clock.State(time.Now()),
+		Status:       statusNames[status],
+		Chess960:     gs.Chess960,
+	}
+	if gs.Board.Variant != nil {
+		saved.Variant = gs.Board.Variant.Name()
+	}
+	if saved.StartFen == "" {
+		saved.StartFen = saved.Fen
+	}
+	for i, mv := range gs.MoveHistory {
+		saved.Moves[i] = mv.ToLAN()
+	}
+	for color, name := range drawOfferNames {
+		if gs.DrawOfferedBy == color {
+			saved.DrawOffer = name
+		}
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not save the game to %s. %w", path, err)
+	}
+	return nil
+}
+
+// Reads a game saved by Save. The game has to be started unless it is paused
+// or already over.
+func Load(path string) (*GameState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open the game in %s. %w", path, err)
+	}
+
+	var saved SavedGame
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the game in %s. %w", path, err)
+	}
+	gs, err := saved.restore()
+	if err != nil {
+		return nil, fmt.Errorf("Could not load the game in %s. %w", path, err)
+	}
+	return gs, nil
+}
+
+func (saved SavedGame) restore() (*GameState, error) {
+	if saved.Version > SAVE_VERSION {
+		return nil, fmt.Errorf("It was saved by a newer version of tchess")
+	}
+	if saved.Version == 0 {
+		// Correspondence games were always played by two humans
+		saved.WhiteIsHuman = true
+		saved.BlackIsHuman = true
+		saved.Status = statusNames[STATUS_PLAYING]
+		if saved.Paused {
+			saved.Status = statusNames[STATUS_PAUSED]
+		}
+	}
+
+	status, ok := statusFromName(saved.Status)
+	if !ok {
+		return nil, fmt.Errorf("Unknown status '%s'", saved.Status)
+	}
+	if saved.StartFen == "" {
+		return nil, fmt.Errorf("There is no starting position")
+	}
+	c, err := clock.Restore(saved.Clock, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := variant.ByName(saved.Variant)
+	if err != nil {
+		return nil, err
+	}
+
+	gs := CreateDefault()
+	gs.Board.Variant = v
+	gs.startFen = saved.StartFen
+	err = gs.LoadFen(saved.StartFen)
+	if err != nil {
+		return nil, err
+	}
+	gs.Board.ClearLastMove()
+	// Promotions used to be saved without the piece, which was always a queen
+	gs.AutoQueen = true
+	for _, lan := range saved.Moves {
+		err = gs.ParseAndExecuteAlgebraicNotation(lan)
+		if err != nil {
+			return nil, fmt.Errorf("Could not replay %s. %w", lan, err)
+		}
+	}
+	gs.AutoQueen = false
+	gs.Chess960 = saved.Chess960
+
+	for color, name := range drawOfferNames {
+		if saved.DrawOffer == name {
+			gs.DrawOfferedBy = color
+		}
+	}
+
+	gs.Clock = c
+	gs.WhiteIsHuman = saved.WhiteIsHuman
+	gs.BlackIsHuman = saved.BlackIsHuman
+	if gs.Status == STATUS_NOT_STARTED && status != STATUS_PLAYING {
+		// Results that the moves do not tell, such as a timeout
+		gs.Status = status
+	}
+	return gs, nil
+}
@@ -0,0 +1,130 @@
+package gamestate
+
+import (
+	"time"
+
+	"github.com/Jesselli/tchess/board"
+)
+
+// Renderer shows the game to the players. The GameState notifies its Renderer
+// of every change instead of drawing itself, so that the game logic does not
+// depend on the terminal. Notifications are delivered on the goroutine that
+// changes the GameState, normally the one running the Loop.
+type Renderer interface {
+	// A move has been played and the GameState already reflects it
+	MoveMade(gs *GameState, mv board.Move)
+	// The clock of the active player has advanced
+	ClockTick(gs *GameState)
+	// The game started, ended, or was abandoned
+	StatusChanged(gs *GameState)
+	// A message for the players, e.g. why a move was rejected
+	Message(gs *GameState, msg string)
+	// Everything needs to be drawn again
+	Redraw(gs *GameState)
+	// The input prompt needs to be drawn with the text typed so far
+	Prompt(gs *GameState, input string)
+}
+
+type nopRenderer struct{}
+
+func (nopRenderer) MoveMade(gs *GameState, mv board.Move) {}
+func (nopRenderer) ClockTick(gs *GameState)               {}
+func (nopRenderer) StatusChanged(gs *GameState)           {}
+func (nopRenderer) Message(gs *GameState, msg string)     {}
+func (nopRenderer) Redraw(gs *GameState)                  {}
+func (nopRenderer) Prompt(gs *GameState, input string)    {}
+
+type EventType string
+
+const (
+	EVENT_MOVE_MADE      EventType = "move"
+	EVENT_CLOCK_TICK     EventType = "tick"
+	EVENT_STATUS_CHANGED EventType = "status"
+	EVENT_MESSAGE        EventType = "message"
+	EVENT_REDRAW         EventType = "redraw"
+	EVENT_PROMPT         EventType = "prompt"
+)
+
+type Event struct {
+	Type    EventType
+	Move    board.Move // Only set for EVENT_MOVE_MADE
+	Status  Status
+	Message string // Message text, or the input for EVENT_PROMPT
+}
+
+// Recorder is a Renderer that keeps every notification in memory, so that
+// tests can check what would have been shown.
+type Recorder struct {
+	Events []Event
+}
+
+func (r *Recorder) record(gs *GameState, ev Event) {
+	ev.Status = gs.Status
+	r.Events = append(r.Events, ev)
+}
+
+func (r *Recorder) MoveMade(gs *GameState, mv board.Move) {
+	r.record(gs, Event{Type: EVENT_MOVE_MADE, Move: mv})
+}
+
+func (r *Recorder) ClockTick(gs *GameState) {
+	r.record(gs, Event{Type: EVENT_CLOCK_TICK})
+}
+
+func (r *Recorder) StatusChanged(gs *GameState) {
+	r.record(gs, Event{Type: EVENT_STATUS_CHANGED})
+}
+
+func (r *Recorder) Message(gs *GameState, msg string) {
+	r.record(gs, Event{Type: EVENT_MESSAGE, Message: msg})
+}
+
+func (r *Recorder) Redraw(gs *GameState) {
+	r.record(gs, Event{Type: EVENT_REDRAW})
+}
+
+func (r *Recorder) Prompt(gs *GameState, input string) {
+	r.record(gs, Event{Type: EVENT_PROMPT, Message: input})
+}
+
+// Returns the recorded events of the given type
+func (r *Recorder) Filter(t EventType) []Event {
+	events := make([]Event, 0)
+	for _, ev := range r.Events {
+		if ev.Type == t {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func (gs *GameState) notify(f func(r Renderer)) {
+	f(gs.Renderer)
+}
+
+func (gs *GameState) SetMessage(msg string) {
+	gs.notify(func(r Renderer) { r.Message(gs, msg) })
+}
+
+func (gs *GameState) SetStatus(status Status) {
+	if gs.Status == status {
+		return
+	}
+	gs.Status = status
+	if status != STATUS_PLAYING {
+		// The clocks stop once the game is over or paused
+		gs.Clock.Stop(time.Now())
+	}
+	if status != STATUS_QUIT {
+		gs.autosave()
+	}
+	gs.notify(func(r Renderer) { r.StatusChanged(gs) })
+}
+
+// Redraws the whole game and the input prompt with the text typed so far
+func (gs *GameState) Redraw(input string) {
+	gs.notify(func(r Renderer) {
+		r.Redraw(gs)
+		r.Prompt(gs, input)
+	})
+}
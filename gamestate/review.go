@@ -0,0 +1,204 @@
+package gamestate
+
+import (
+	"fmt"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/clock"
+	"github.com/Jesselli/tchess/piece"
+)
+
+// Review mode lets the user step through the MoveHistory of a finished game.
+// The position shown for a given ply is taken from the BoardHistory, with the
+// counters worked out from the moves made since the start position.
+
+func (gs *GameState) positionAt(ply int) (*GameState, error) {
+	start, err := gs.parseFen(gs.startFen)
+	if err != nil {
+		return nil, fmt.Errorf("Could not set up the start position. %w", err)
+	}
+
+	pos := CreateDefault()
+	pos.Board = gs.Board
+	if ply < len(gs.BoardHistory) {
+		pos.Board = gs.BoardHistory[ply]
+	}
+	pos.Chess960 = gs.Chess960
+	pos.startFen = gs.startFen
+	// Capped so that a branch does not append into the moves it replaces
+	pos.MoveHistory = gs.MoveHistory[:ply:ply]
+	pos.BoardHistory = gs.BoardHistory[:ply:ply]
+
+	var black bool
+	pos.FullMoveCount, black = gs.MoveNumber(ply)
+	pos.ActiveColor = piece.WHITE
+	if black {
+		pos.ActiveColor = piece.BLACK
+	}
+	pos.enPassantSq = -1
+	if ply == 0 {
+		pos.enPassantSq = start.EnPassantSq
+	}
+	pos.HalfMoveClock = start.HalfMoveClock + ply
+	for i := ply - 1; i >= 0; i-- {
+		if mv := gs.MoveHistory[i]; mv.Piece == piece.PAWN || mv.Capture {
+			pos.HalfMoveClock = ply - 1 - i
+			break
+		}
+	}
+	return pos, nil
+}
+
+func (gs *GameState) CanReview() bool {
+	return gs.Status != STATUS_PLAYING && gs.Status != STATUS_NOT_STARTED && gs.Status != STATUS_PAUSED && gs.startFen != ""
+}
+
+func (gs *GameState) StartReview() error {
+	if !gs.CanReview() {
+		return fmt.Errorf("Positions can only be reviewed once the game is over")
+	}
+	if _, err := gs.positionAt(0); err != nil {
+		return err
+	}
+	if !gs.Reviewing {
+		gs.Reviewing = true
+		gs.ReviewPly = len(gs.MoveHistory)
+	}
+	return nil
+}
+
+func (gs *GameState) ReviewGoto(ply int) error {
+	err := gs.StartReview()
+	if err != nil {
+		return err
+	}
+
+	if ply < 0 {
+		ply = 0
+	} else if ply > len(gs.MoveHistory) {
+		ply = len(gs.MoveHistory)
+	}
+	gs.ReviewPly = ply
+	return nil
+}
+
+func (gs *GameState) ReviewFirst() error {
+	return gs.ReviewGoto(0)
+}
+
+func (gs *GameState) ReviewLast() error {
+	return gs.ReviewGoto(len(gs.MoveHistory))
+}
+
+func (gs *GameState) ReviewNext() error {
+	err := gs.StartReview()
+	if err == nil {
+		err = gs.ReviewGoto(gs.ReviewPly + 1)
+	}
+	return err
+}
+
+func (gs *GameState) ReviewPrev() error {
+	err := gs.StartReview()
+	if err == nil {
+		err = gs.ReviewGoto(gs.ReviewPly - 1)
+	}
+	return err
+}
+
+// Jumps to the position after the given move number, numbered the same way
+// as the move history. If black is true, the position after black's reply is
+// shown instead of the one after white's move.
+func (gs *GameState) ReviewGotoMove(moveNum int, black bool) error {
+	if moveNum < 1 {
+		return fmt.Errorf("Move numbers start at 1")
+	}
+	if len(gs.MoveHistory) == 0 {
+		return fmt.Errorf("The game has no moves")
+	}
+	ply := 2*(moveNum-1) - gs.firstPly() + 1
+	if black {
+		ply++
+	}
+	if first, _ := gs.MoveNumber(0); ply < 1 {
+		return fmt.Errorf("The game starts at move %d", first)
+	}
+	if last, _ := gs.MoveNumber(len(gs.MoveHistory) - 1); ply > len(gs.MoveHistory) {
+		return fmt.Errorf("The game ends at move %d", last)
+	}
+	return gs.ReviewGoto(ply)
+}
+
+func (gs *GameState) ReviewMessage() string {
+	if gs.ReviewPly == 0 {
+		return fmt.Sprintf("Reviewing start position (0/%d)", len(gs.MoveHistory))
+	}
+
+	moveNum, black := gs.MoveNumber(gs.ReviewPly - 1)
+	dots := "."
+	if black {
+		dots = "..."
+	}
+	return fmt.Sprintf("Reviewing %d%s %s (%d/%d)", moveNum, dots, gs.MoveSAN(gs.ReviewPly-1), gs.ReviewPly, len(gs.MoveHistory))
+}
+
+// Abandons the finished game and continues playing from the position that is
+// currently being reviewed. The moves after that position are discarded and
+// both clocks are reset to the original time control.
+func (gs *GameState) BranchFromReview() error {
+	if !gs.Reviewing {
+		return fmt.Errorf("Select a position to branch from first")
+	}
+
+	pos, err := gs.positionAt(gs.ReviewPly)
+	if err != nil {
+		return err
+	}
+	gs.Board = pos.Board
+	gs.ActiveColor = pos.ActiveColor
+	gs.enPassantSq = pos.enPassantSq
+	gs.HalfMoveClock = pos.HalfMoveClock
+	gs.FullMoveCount = pos.FullMoveCount
+	gs.MoveHistory = pos.MoveHistory
+	gs.BoardHistory = pos.BoardHistory
+	gs.Clock = clock.New(gs.Clock.Control)
+	gs.Reviewing = false
+	gs.ReviewPly = 0
+	gs.DrawOfferedBy = 0
+
+	gs.StartGame()
+	return nil
+}
+
+// Standard algebraic notation of the move at the given index of the MoveHistory
+func (gs *GameState) MoveSAN(idx int) string {
+	before, _ := gs.BoardsAroundMove(idx)
+	return before.ToSAN(gs.MoveHistory[idx])
+}
+
+// Move number of the move at the given index of the MoveHistory and whether
+// black made it. Games set up from a position may start at any move.
+func (gs *GameState) MoveNumber(idx int) (int, bool) {
+	ply := gs.firstPly() + idx
+	return ply/2 + 1, ply%2 == 1
+}
+
+// Half moves made before the first move of the MoveHistory, counted from
+// white's first move
+func (gs *GameState) firstPly() int {
+	ply := 2*(gs.FullMoveCount-1) - len(gs.MoveHistory)
+	if gs.ActiveColor == piece.BLACK {
+		ply++
+	}
+	return ply
+}
+
+// Board before and after the move at the given index of the MoveHistory
+func (gs *GameState) BoardsAroundMove(idx int) (board.Board, board.Board) {
+	before := gs.BoardHistory[idx]
+	after := gs.Board
+	if idx+1 < len(gs.BoardHistory) {
+		after = gs.BoardHistory[idx+1]
+	}
+	return before, after
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/gamestate"
+	"github.com/Jesselli/tchess/piece"
+	"github.com/Jesselli/tchess/tui"
+)
+
+// Input state used when the terminal is in raw mode. Commands are edited on
+// the prompt line, and the arrow keys or the mouse can pick up a piece and drop
+// it on one of its legal destinations. Only the goroutine reading stdin uses
+// keys, everything else is only touched on the loop goroutine.
+type inputState struct {
+	keys       *tui.KeyReader // nil when stdin is not a terminal
+	line       []rune
+	cursorSq   int          // -1 while the board cursor is hidden
+	selectedSq int          // -1 while no piece is selected
+	pressSq    int          // Square the mouse button went down on, -1 if none
+	hintSq     int          // Square whose legal moves are shown, -1 if none
+	hintPly    int          // Hints disappear once the position changes
+	candidates []board.Move // Moves an ambiguous move could mean
+	candPly    int          // Candidates are dropped once the position changes
+	promotion  *board.Move  // Move waiting for the piece a pawn promotes to
+}
+
+var input = inputState{cursorSq: -1, selectedSq: -1, pressSq: -1, hintSq: -1}
+
+func ProcessKey(gs *gamestate.GameState, ev tui.KeyEvent, err error) {
+	if err != nil {
+		gs.SetStatus(gamestate.STATUS_QUIT)
+		return
+	}
+
+	switch ev.Key {
+	case tui.KEY_CTRL_C:
+		gs.SetStatus(gamestate.STATUS_QUIT)
+	case tui.KEY_RUNE:
+		input.line = append(input.line, ev.Rune)
+	case tui.KEY_BACKSPACE:
+		if len(input.line) > 0 {
+			input.line = input.line[:len(input.line)-1]
+		}
+	case tui.KEY_ESC:
+		if input.cursorSq < 0 && input.selectedSq < 0 && input.hintSq < 0 && input.candidates == nil && input.promotion == nil {
+			input.line = nil
+		}
+		input.cancelSelection()
+		input.hintSq = -1
+	case tui.KEY_ENTER:
+		if len(input.line) > 0 || input.cursorSq < 0 {
+			line := string(input.line)
+			input.line = nil
+			ProcessCommand(gs, line)
+		} else {
+			input.selectOrMove(gs, input.cursorSq)
+		}
+	case tui.KEY_MOUSE:
+		input.processMouse(gs, ev.Mouse)
+	case tui.KEY_UP:
+		input.moveCursor(gs, 0, -1)
+	case tui.KEY_DOWN:
+		input.moveCursor(gs, 0, 1)
+	case tui.KEY_LEFT:
+		input.moveCursor(gs, -1, 0)
+	case tui.KEY_RIGHT:
+		input.moveCursor(gs, 1, 0)
+	}
+
+	input.updateHighlights(gs)
+}
+
+func canSelectSquares(gs *gamestate.GameState) bool {
+	return gs.Status == gamestate.STATUS_PLAYING && gs.ActivePlayerIsHuman() && !gs.Reviewing
+}
+
+func (in *inputState) cancelSelection() {
+	in.cursorSq = -1
+	in.selectedSq = -1
+	in.pressSq = -1
+	in.candidates = nil
+	in.promotion = nil
+}
+
+// Plays a typed move. If more than one piece can make it, the player is asked
+// which one they meant, and a pawn reaching the last rank asks what it
+// promotes to.
+func (in *inputState) playMove(gs *gamestate.GameState, notation string) {
+	in.candidates = nil
+	in.promotion = nil
+	err := gs.ParseAndExecuteAlgebraicNotation(notation)
+	var ambiguous *board.AmbiguousMoveError
+	var promotion *board.PromotionRequiredError
+	if errors.As(err, &ambiguous) {
+		in.candidates = ambiguous.Candidates
+		in.candPly = len(gs.MoveHistory)
+	} else if errors.As(err, &promotion) {
+		in.promotion = &promotion.Move
+		in.candPly = len(gs.MoveHistory)
+	}
+}
+
+// Plays the pending promotion with the piece named by its letter. Returns
+// false if the choice is not meant for the question.
+func (in *inputState) choosePromotion(gs *gamestate.GameState, choice string) bool {
+	if in.promotion == nil {
+		return false
+	}
+	if len(choice) != 1 {
+		return false
+	}
+
+	mv := *in.promotion
+	mv.Promote = piece.ActiveLanguage.PieceFromLetter(strings.ToUpper(choice)[0])
+	if mv.Promote == piece.NONE || mv.Promote == piece.KING {
+		gs.SetMessage((&board.PromotionRequiredError{Move: mv}).Error())
+		return true
+	}
+	in.cancelSelection()
+	in.playMove(gs, mv.ToLAN())
+	return true
+}
+
+// Plays the candidate of an ambiguous move that the player picked by its
+// source square, file or rank, or as written in the question. Returns false
+// if the choice is not meant for the question, e.g. because it is another move.
+func (in *inputState) chooseCandidate(gs *gamestate.GameState, choice string) bool {
+	if in.candidates == nil {
+		return false
+	}
+
+	matches := make([]board.Move, 0)
+	for _, mv := range in.candidates {
+		src := board.SqNumToStr(mv.SrcSqNum())
+		if choice == src || choice == src[:1] || choice == src[1:] || choice == mv.ToLongAlgebraic() {
+			matches = append(matches, mv)
+		}
+	}
+
+	if len(matches) == 1 {
+		in.cancelSelection()
+		in.playMove(gs, matches[0].ToLAN())
+		return true
+	}
+	if len(choice) == 1 || len(matches) > 1 {
+		// A lone file or rank can only be an answer, ask again
+		gs.SetMessage((&board.AmbiguousMoveError{Candidates: in.candidates}).Error())
+		return true
+	}
+	return false
+}
+
+// Returns true if one of the candidates of an ambiguous move starts on sq
+func (in *inputState) isCandidateSq(sq int) bool {
+	for _, mv := range in.candidates {
+		if mv.SrcSqNum() == sq {
+			return true
+		}
+	}
+	return false
+}
+
+// Moves the cursor in screen directions, so up is always towards the top of
+// the terminal regardless of whether the board is rotated.
+func (in *inputState) moveCursor(gs *gamestate.GameState, dx, dy int) {
+	if !canSelectSquares(gs) {
+		return
+	}
+
+	if in.cursorSq < 0 {
+		in.cursorSq = in.selectedSq
+		if in.cursorSq < 0 {
+			// White has no king in Horde, the cursor then starts in the corner
+			in.cursorSq, _ = gs.Board.KingSq(gs.ActiveColor)
+			in.cursorSq = max(in.cursorSq, 0)
+		}
+		return
+	}
+
+	if gs.IsBoardRotated() {
+		dx, dy = -dx, -dy
+	}
+	if sq, ok := board.SqNumPlusDelta(in.cursorSq, [2]int{dx, dy}); ok {
+		in.cursorSq = sq
+	}
+}
+
+func (in *inputState) selectOrMove(gs *gamestate.GameState, sq int) {
+	if !canSelectSquares(gs) {
+		in.cancelSelection()
+		return
+	}
+
+	p := gs.Board.Pieces[sq]
+	if in.isCandidateSq(sq) {
+		in.chooseCandidate(gs, board.SqNumToStr(sq))
+	} else if in.selectedSq >= 0 && p.Color == gs.ActiveColor && in.isTarget(gs, sq) {
+		// The king castles by taking its own rook
+		in.playSelectedMove(gs, sq)
+	} else if p.Color == gs.ActiveColor {
+		if len(gs.Board.ValidMovesFrom(sq, gs.ActiveColor)) == 0 {
+			gs.SetMessage("That piece has no legal moves")
+		} else {
+			in.selectedSq = sq
+		}
+	} else if in.selectedSq >= 0 && in.isTarget(gs, sq) {
+		in.playSelectedMove(gs, sq)
+	} else {
+		gs.SetMessage("Select one of your pieces")
+	}
+}
+
+// A click selects a piece or plays the selected piece to the clicked square.
+// Releasing the button over a different square than the one it was pressed on
+// drops the dragged piece there.
+func (in *inputState) processMouse(gs *gamestate.GameState, ev tui.MouseEvent) {
+	if ev.Button != tui.MOUSE_LEFT || ev.IsWheel || ev.Drag {
+		return
+	}
+
+	sq, onBoard := board.SqAtScreenPos(screen.Layout, ev.Row, ev.Col, gs.IsBoardRotated())
+	if ev.Press {
+		in.pressSq = -1
+		if onBoard {
+			in.cursorSq = -1
+			in.pressSq = sq
+			in.selectOrMove(gs, sq)
+		} else {
+			in.cancelSelection()
+		}
+		return
+	}
+
+	pressSq := in.pressSq
+	in.pressSq = -1
+	if onBoard && sq != pressSq && in.selectedSq == pressSq && in.selectedSq >= 0 {
+		if in.isTarget(gs, sq) {
+			in.playSelectedMove(gs, sq)
+		} else {
+			gs.SetMessage("Illegal move")
+		}
+	}
+}
+
+func (in *inputState) isTarget(gs *gamestate.GameState, sq int) bool {
+	_, ok := in.selectedMoveTo(gs, sq)
+	return ok
+}
+
+// Returns the legal move of the selected piece to sq. Castling can also be
+// chosen on the rook's square, which is the only way in Chess960 when the king
+// already stands on or next to its target.
+func (in *inputState) selectedMoveTo(gs *gamestate.GameState, sq int) (board.Move, bool) {
+	var castle *board.Move
+	for _, mv := range gs.Board.ValidMovesFrom(in.selectedSq, gs.ActiveColor) {
+		switch {
+		case !mv.Castle && mv.TrgSqNum() == sq:
+			return mv, true
+		case mv.Castle && sq != in.selectedSq && (mv.TrgSqNum() == sq || mv.RookSqNum() == sq):
+			castle = &mv
+		}
+	}
+	if castle != nil {
+		return *castle, true
+	}
+	return board.Move{}, false
+}
+
+func (in *inputState) playSelectedMove(gs *gamestate.GameState, trgSq int) {
+	mv, _ := in.selectedMoveTo(gs, trgSq)
+	notation := board.SqNumToStr(in.selectedSq) + board.SqNumToStr(trgSq)
+	if mv.Castle {
+		// The king taking its own rook is always read as castling
+		notation = board.SqNumToStr(in.selectedSq) + board.SqNumToStr(mv.RookSqNum())
+	}
+	in.cancelSelection()
+	in.playMove(gs, notation)
+}
+
+// Shows the legal destinations of the piece on the given square until the
+// next move is made.
+func (in *inputState) showHint(gs *gamestate.GameState, sqName string) error {
+	if !canSelectSquares(gs) {
+		return fmt.Errorf("Hints are only available on your turn")
+	}
+	if len(sqName) != 2 || sqName[0] < 'a' || sqName[0] > 'h' || sqName[1] < '1' || sqName[1] > '8' {
+		return fmt.Errorf("Usage: hint <square>, e.g. 'hint g1'")
+	}
+
+	sq := board.StrToSqNum(sqName)
+	p := gs.Board.Pieces[sq]
+	if p == piece.EMPTYP {
+		return fmt.Errorf("There is no piece on %s", sqName)
+	}
+
+	mvs := gs.Board.ValidMovesFrom(sq, p.Color)
+	if len(mvs) == 0 {
+		return fmt.Errorf("The %s on %s has no legal moves", p.Name(), sqName)
+	}
+
+	in.hintSq = sq
+	in.hintPly = len(gs.MoveHistory)
+	gs.SetMessage(fmt.Sprintf("The %s on %s has %d legal moves", p.Name(), sqName, len(mvs)))
+	return nil
+}
+
+func (in *inputState) updateHighlights(gs *gamestate.GameState) {
+	gs.Board.ClearHighlights()
+	if !canSelectSquares(gs) {
+		in.cancelSelection()
+		in.hintSq = -1
+		return
+	}
+
+	if in.hintSq >= 0 && in.hintPly != len(gs.MoveHistory) {
+		in.hintSq = -1
+	}
+	if (in.candidates != nil || in.promotion != nil) && in.candPly != len(gs.MoveHistory) {
+		in.candidates = nil
+		in.promotion = nil
+	}
+	if in.promotion != nil {
+		gs.Board.Highlights[in.promotion.SrcSqNum()] = board.HIGHLIGHT_SELECTED
+		gs.Board.Highlights[in.promotion.TrgSqNum()] = board.HIGHLIGHT_TARGET
+	}
+	for _, mv := range in.candidates {
+		gs.Board.Highlights[mv.TrgSqNum()] = board.HIGHLIGHT_TARGET
+		gs.Board.Highlights[mv.SrcSqNum()] = board.HIGHLIGHT_SELECTED
+	}
+
+	if in.selectedSq < 0 && in.hintSq >= 0 {
+		p := gs.Board.Pieces[in.hintSq]
+		for _, mv := range gs.Board.ValidMovesFrom(in.hintSq, p.Color) {
+			gs.Board.Highlights[mv.TrgSqNum()] = board.HIGHLIGHT_TARGET
+		}
+		gs.Board.Highlights[in.hintSq] = board.HIGHLIGHT_SELECTED
+	}
+
+	if in.selectedSq >= 0 {
+		for _, mv := range gs.Board.ValidMovesFrom(in.selectedSq, gs.ActiveColor) {
+			gs.Board.Highlights[mv.TrgSqNum()] = board.HIGHLIGHT_TARGET
+			if mv.Castle {
+				gs.Board.Highlights[mv.RookSqNum()] = board.HIGHLIGHT_TARGET
+			}
+		}
+		gs.Board.Highlights[in.selectedSq] = board.HIGHLIGHT_SELECTED
+	}
+	if in.cursorSq >= 0 {
+		gs.Board.Highlights[in.cursorSq] = board.HIGHLIGHT_CURSOR
+	}
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/Jesselli/tchess/clock"
+	"github.com/Jesselli/tchess/fen"
 	"github.com/Jesselli/tchess/gamestate"
+	"github.com/Jesselli/tchess/piece"
+	"github.com/Jesselli/tchess/render"
 	"github.com/Jesselli/tchess/tui"
 	"github.com/Jesselli/tchess/uci"
+	"github.com/Jesselli/tchess/variant"
 )
 
 const (
@@ -16,16 +27,120 @@ const (
 	blackPlayerDefault = ""
 	blackPlayerHelp    = "Name of UCI executable on PATH. If empty, player is human"
 	timeControlDefault = "15m|5s"
-	timeControlHelp    = "5m|5s would be 5mins with a 5sec increment"
+	timeControlHelp    = "5m|5s or 5+5 is 5mins with a 5sec increment. 40/90+30,G/30+30 plays in stages. Prefix with delay:, bronstein: or hourglass: to change how time is added"
+	themeDefault       = "blue"
+	themeHelp          = "Board theme: blue, green, brown, gray, or the path to a JSON theme file"
+	piecesDefault      = ""
+	piecesHelp         = "Piece glyphs: filled, outlined, ascii or nerd. Overrides the theme"
+	colorsDefault      = "auto"
+	colorsHelp         = "Terminal color support: auto, truecolor, 256 or 16"
+	plainDefault       = false
+	plainHelp          = "Write the board as plain text and describe moves in words. Default when stdout is not a terminal"
+	corrFileHelp       = "File a correspondence game (-tc corr:<days>) is kept in between moves"
+	resumeDefault      = ""
+	resumeHelp         = "Continue the game saved in this file. Players and time control are taken from the file"
+	fenDefault         = ""
+	fenHelp            = "Start from the position in this FEN record instead of the initial position"
+	epdDefault         = ""
+	epdHelp            = "Start from the position in this EPD line. hmvc and fmvn set the move counters"
+	autoQueenDefault   = false
+	autoQueenHelp      = "Promote pawns to queens without asking, unless the move names another piece"
+	langDefault        = "en"
+	langHelp           = "Language of the piece letters in moves, e.g. de for Sf3 and Dxd7. One of "
+	figurinesDefault   = false
+	figurinesHelp      = "Show moves with the piece glyphs instead of letters, e.g. ♘f3. Not with ascii glyphs"
+	chess960Default    = ""
+	chess960Help       = "Start from a Chess960 position: its number from 0 to 959, or random"
+	variantDefault     = "standard"
+	variantHelp        = "Rules to play by, one of "
 )
 
+const (
+	helpMsg       = "Enter a move using algebraic notation or pick one with the mouse or the arrow keys and Enter. 'hint <square>' shows a piece's moves. 'offer draw', 'accept', 'decline', 'claim [move]' and 'resign' end the game. Or 'setup <fen>', 'save <file>', 'load <file>', 'pause', 'resume' and 'quit'."
+	reviewHelpMsg = "Review with first, prev, next, last or goto <n>. 'branch' plays on from the shown position."
+)
+
+const engineName = "stockfish"
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// Terminal renderer, nil in plain mode
+var screen *render.ANSI
+
+// Engine playing the non-human players, nil if both are human
+var engine gamestate.Engine
+
+// Shown once the game has started, e.g. the number of a random position
+var startMessage string
+
 func parseFlags(gs *gamestate.GameState) error {
 	var whitePlayer = flag.String("wp", whitePlayerDefault, whitePlayerHelp)
 	var blackPlayer = flag.String("bp", blackPlayerDefault, blackPlayerHelp)
 	var timeControl = flag.String("tc", timeControlDefault, timeControlHelp)
+	var theme = flag.String("theme", themeDefault, themeHelp)
+	var pieces = flag.String("pieces", piecesDefault, piecesHelp)
+	var colors = flag.String("colors", colorsDefault, colorsHelp)
+	var plain = flag.Bool("plain", plainDefault, plainHelp)
+	var corrFile = flag.String("corrfile", corrFileDefault(), corrFileHelp)
+	var resume = flag.String("resume", resumeDefault, resumeHelp)
+	var startFen = flag.String("fen", fenDefault, fenHelp)
+	var startEpd = flag.String("epd", epdDefault, epdHelp)
+	var autoQueen = flag.Bool("autoqueen", autoQueenDefault, autoQueenHelp)
+	var lang = flag.String("lang", langDefault, langHelp+strings.Join(piece.LanguageCodes, ", "))
+	var figurines = flag.Bool("figurines", figurinesDefault, figurinesHelp)
+	var chess960 = flag.String("chess960", chess960Default, chess960Help)
+	var variantName = flag.String("variant", variantDefault, variantHelp+strings.Join(variant.Names, ", "))
 	flag.Parse()
 
-	var err error
+	language, ok := piece.Languages[*lang]
+	if !ok {
+		return fmt.Errorf("Unknown notation language '%s'. Use one of %s", *lang, strings.Join(piece.LanguageCodes, ", "))
+	}
+	piece.ActiveLanguage = language
+
+	gs.AutoQueen = *autoQueen
+
+	tui.PlainMode = *plain || !tui.IsTerminal(int(os.Stdout.Fd()))
+
+	err := parseThemeFlags(*theme, *pieces, *colors)
+	if err != nil {
+		return err
+	}
+	if tui.PlainMode {
+		tui.ActiveTheme.Pieces = piece.GLYPHS_ASCII
+	}
+	// ASCII glyphs are the English letters, which the piece letters already are
+	if *figurines && tui.ActiveTheme.Pieces != piece.GLYPHS_ASCII {
+		piece.MoveFigurines = tui.ActiveTheme.Pieces
+	}
+
+	if *startFen != "" && *startEpd != "" || *chess960 != "" && (*startFen != "" || *startEpd != "") {
+		return fmt.Errorf("Use only one of -fen, -epd and -chess960")
+	}
+	if *resume != "" && (*startFen != "" || *startEpd != "" || *chess960 != "") {
+		return fmt.Errorf("A resumed game continues from its own position, leave out -fen, -epd and -chess960")
+	}
+	v, err := variant.ByName(*variantName)
+	if err != nil {
+		return err
+	}
+	if v != nil && *resume != "" {
+		return fmt.Errorf("A resumed game keeps its own variant, leave out -variant")
+	}
+	if v != nil && *chess960 != "" {
+		return fmt.Errorf("Use either -variant or -chess960")
+	}
+	// Set before any setup, so that positions are read by the rules of the variant
+	gs.Board.Variant = v
+
+	if *resume != "" {
+		loaded, err := gamestate.Load(*resume)
+		if err == nil {
+			replaceGame(gs, loaded, *resume)
+		}
+		return err
+	}
+
 	if *whitePlayer != "" {
 		// TODO: Use this value as the engine executable
 		gs.WhiteIsHuman = false
@@ -36,26 +151,213 @@ func parseFlags(gs *gamestate.GameState) error {
 
 	err = gs.ParseTimeControlFlag(*timeControl)
 	if err != nil {
-		err = fmt.Errorf("Time control should be of the format 5m|5s. %w", err)
+		return fmt.Errorf("%w\n%s", err, clock.FormatHelp)
+	}
+
+	if *startFen != "" {
+		err = gs.Setup(*startFen)
+	} else if *startEpd != "" {
+		_, err = gs.SetupEPD(*startEpd)
+	} else if *chess960 != "" {
+		err = setupChess960(gs, *chess960)
+	} else if v != nil {
+		err = gs.SetupVariant(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	newPosition := *startFen != "" || *startEpd != "" || *chess960 != "" || v != nil
+	if gs.Clock.Control.Method == clock.METHOD_CORRESPONDENCE && !newPosition {
+		err = openCorrespondence(gs, *corrFile)
+	} else if gs.Clock.Control.Method == clock.METHOD_CORRESPONDENCE {
+		// A new game from the given position replaces the saved one
+		gs.AutosavePath = *corrFile
+	}
+	return err
+}
+
+// Sets up the numbered Chess960 position, or a random one
+func setupChess960(gs *gamestate.GameState, arg string) error {
+	n := rand.Intn(fen.CHESS960_POSITIONS)
+	if arg != "random" {
+		var err error
+		n, err = strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("-chess960 takes a position number from 0 to %d, or random", fen.CHESS960_POSITIONS-1)
+		}
+	}
+	err := gs.SetupChess960(n)
+	if err == nil {
+		startMessage = fmt.Sprintf("Chess960 position #%d", n)
 	}
 	return err
 }
 
-func DrawMessagePrompt(gs *gamestate.GameState) {
-	msg := gs.Message
-	if gs.Status != gamestate.STATUS_PLAYING {
-		msg = string(gs.Status)
+func corrFileDefault() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "tchess-correspondence.json"
+	}
+	return filepath.Join(dir, "tchess", "correspondence.json")
+}
+
+// Continues the correspondence game saved in the file, unless it is over, and
+// keeps saving the game there after every move
+func openCorrespondence(gs *gamestate.GameState, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		saved, err := gamestate.Load(path)
+		if err != nil {
+			return err
+		}
+		if saved.Status == gamestate.STATUS_NOT_STARTED || saved.Status == gamestate.STATUS_PAUSED {
+			saved.WhiteIsHuman = gs.WhiteIsHuman
+			saved.BlackIsHuman = gs.BlackIsHuman
+			replaceGame(gs, saved, path)
+		}
+	}
+	gs.AutosavePath = path
+	return nil
+}
+
+// Continues with a game loaded from the given file. A correspondence game keeps
+// being saved to the file it came from.
+func replaceGame(gs *gamestate.GameState, loaded *gamestate.GameState, path string) {
+	loaded.Renderer = gs.Renderer
+	loaded.AutoQueen = gs.AutoQueen
+	if loaded.Clock.Control.Method == clock.METHOD_CORRESPONDENCE {
+		loaded.AutosavePath = path
+	}
+	*gs = *loaded
+}
+
+// Starts a new game from a FEN record, or an EPD line if it has operations
+func setupPosition(gs *gamestate.GameState, record string) error {
+	var err error
+	if record == "" {
+		return fmt.Errorf("Usage: setup <fen>, e.g. 'setup %s'", fen.DEFAULT)
+	} else if strings.Contains(record, ";") {
+		_, err = gs.SetupEPD(record)
+	} else {
+		err = gs.Setup(record)
+	}
+	if err != nil {
+		return err
+	}
+
+	input.cancelSelection()
+	input.hintSq = -1
+	gs.StartGame()
+	return nil
+}
+
+func saveOrLoad(gs *gamestate.GameState, cmd string, path string) error {
+	if path == "" {
+		return fmt.Errorf("Usage: %s <file>", cmd)
+	}
+	if cmd == "save" {
+		err := gs.Save(path)
+		if err == nil {
+			gs.SetMessage(fmt.Sprintf("Saved the game to %s", path))
+		}
+		return err
 	}
 
-	tui.MoveCursorTo(11, 0)
-	tui.EraseLine()
-	fmt.Fprintf(os.Stdout, "%s\n", msg)
-	tui.EraseLine()
-	fmt.Fprintf(os.Stdout, "> ")
-	gs.Message = ""
+	loaded, err := gamestate.Load(path)
+	if err != nil {
+		return err
+	}
+	if engine == nil && (!loaded.WhiteIsHuman || !loaded.BlackIsHuman) {
+		return fmt.Errorf("The game in %s is played by an engine. Start tchess with -wp or -bp to load it", path)
+	}
+	// The engine was told the variant when it started
+	if engine != nil && loaded.Board.Rules().Name() != gs.Board.Rules().Name() {
+		return fmt.Errorf("The game in %s is played by the rules of %s. Start tchess with -variant %s to load it", path, loaded.Board.Rules().Name(), loaded.Board.Rules().Name())
+	}
+
+	replaceGame(gs, loaded, path)
+	input.cancelSelection()
+	input.hintSq = -1
+	if gs.Status == gamestate.STATUS_NOT_STARTED {
+		gs.StartGame()
+	}
+	gs.SetMessage(fmt.Sprintf("Loaded %s", path))
+	return nil
+}
+
+func parseThemeFlags(theme, pieces, colors string) error {
+	var err error
+	tui.ActiveTheme, err = tui.LoadTheme(theme)
+	if err != nil {
+		return err
+	}
+
+	if pieces != "" {
+		tui.ActiveTheme.Pieces = pieces
+	}
+	if !piece.IsGlyphSet(tui.ActiveTheme.Pieces) {
+		return fmt.Errorf("Unknown piece glyphs '%s'. Use one of %s", tui.ActiveTheme.Pieces, strings.Join(piece.GlyphSetNames, ", "))
+	}
+
+	if colors == "auto" {
+		tui.ActiveColorMode = tui.DetectColorMode()
+	} else if mode, ok := tui.ColorModeNames[colors]; ok {
+		tui.ActiveColorMode = mode
+	} else {
+		return fmt.Errorf("Unknown color support '%s'. Use auto, truecolor, 256 or 16", colors)
+	}
+	if tui.ActiveColorMode == tui.COLOR_MODE_16 {
+		tui.ActiveTheme = tui.ActiveTheme.For16Colors()
+	}
+	return nil
+}
+
+// Reflows the screen for the new terminal size whenever it is resized
+func RedrawOnResize(loop *gamestate.Loop) {
+	resized := make(chan os.Signal, 1)
+	tui.NotifyResize(resized)
+	for range resized {
+		if !loop.Do(func(gs *gamestate.GameState) { screen.Resize() }) {
+			return
+		}
+	}
+}
+
+// Reads keys, or whole lines when stdin is not a raw terminal, and hands them
+// to the loop until it stops
+func ReadInput(loop *gamestate.Loop) {
+	for {
+		var process func(gs *gamestate.GameState)
+		if input.keys != nil {
+			ev, err := input.keys.ReadKey()
+			process = func(gs *gamestate.GameState) { ProcessKey(gs, ev, err) }
+		} else {
+			line, err := stdin.ReadString('\n')
+			process = func(gs *gamestate.GameState) {
+				ProcessCommand(gs, line)
+				if err != nil {
+					gs.SetStatus(gamestate.STATUS_QUIT)
+				}
+				input.updateHighlights(gs)
+			}
+		}
+
+		if !loop.Do(process) {
+			return
+		}
+	}
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "epd" {
+		err := runEPD(os.Args[2:])
+		if err != nil && err != flag.ErrHelp {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	gs := gamestate.CreateDefault()
 	err := parseFlags(gs)
 	if err != nil {
@@ -63,17 +365,43 @@ func main() {
 		return
 	}
 
-	var uciPipe uci.Pipe
 	if !gs.WhiteIsHuman || !gs.BlackIsHuman {
 		// TODO: Remove hard-coded 'stockfish' as the engine
-		uciPipe, err = uci.CreatePipe("stockfish")
-		if err == nil {
-			uciPipe.Send(uci.UCI_SEND_UCI)
-			uciPipe.WaitForExpected(uci.UCI_RECV_UCIOK)
-		} else {
+		uciPipe, err := uci.CreatePipe(engineName)
+		if err != nil {
 			fmt.Println(err.Error())
 			return
 		}
+		uciPipe.Handshake()
+		if gs.Chess960 {
+			uciPipe.SetOption("UCI_Chess960", "true")
+		}
+		if v := gs.Board.Variant; v != nil {
+			if !uciPipe.SupportsVariant(v.Name()) {
+				fmt.Printf("%s can not play %s, it does not offer it as UCI_Variant\n", engineName, v.Name())
+				return
+			}
+			uciPipe.SetOption("UCI_Variant", v.Name())
+		}
+		engine = &uciPipe
+	}
+
+	err = play(gs)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+// Sets up the terminal and runs the game until a player quits
+func play(gs *gamestate.GameState) error {
+	stdinFd := int(os.Stdin.Fd())
+	if tui.PlainMode {
+		// Keep line based input so that typed commands are echoed
+	} else if termState, err := tui.EnableRawMode(stdinFd); err == nil {
+		defer tui.RestoreMode(stdinFd, termState)
+		input.keys = tui.NewKeyReader(os.Stdin)
+		tui.SetMouseReporting(true)
+		defer tui.SetMouseReporting(false)
 	}
 
 	tui.CursorVisible(false)
@@ -82,38 +410,155 @@ func main() {
 	tui.SetAlternateBuffer(true)
 	defer tui.SetAlternateBuffer(false)
 
-	gs.StartGame()
-	for {
-		if gs.Status == gamestate.STATUS_QUIT {
-			break
-		}
+	loop := gamestate.NewLoop(gs, engine)
+	loop.EngineName = engineName
+	loop.PromptText = func() string { return string(input.line) }
 
-		gs.Draw()
-		DrawMessagePrompt(gs)
+	if tui.PlainMode {
+		gs.Renderer = render.NewPlain(os.Stdout)
+	} else {
+		screen = render.NewANSI()
+		screen.Resize()
+		gs.Renderer = screen
+		go RedrawOnResize(loop)
+	}
 
-		if gs.ActivePlayerIsHuman() {
-			PromptAndProcessUserInput(gs)
-		} else if !gs.ActivePlayerIsHuman() && gs.Status == gamestate.STATUS_PLAYING {
-			// Issue the game state to the engine and ask for its move
-			uciPipe.SendPositionFen(gs.ToFen())
-			bestMoveNotation := uciPipe.CalculateBestMove(10)
-			gs.ParseAndExecuteAlgebraicNotation(bestMoveNotation)
-		} else if gs.Status != gamestate.STATUS_PLAYING {
-			PromptAndProcessUserInput(gs)
-		}
+	// Resumed games may be paused or already over
+	if gs.Status == gamestate.STATUS_NOT_STARTED {
+		gs.StartGame()
 	}
+	if startMessage != "" {
+		gs.SetMessage(startMessage)
+	}
+	go ReadInput(loop)
+	return loop.Run(context.Background())
 }
 
-func PromptAndProcessUserInput(gs *gamestate.GameState) {
-	var cmd string
-	fmt.Scanln(&cmd)
+func ProcessCommand(gs *gamestate.GameState, line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	cmd := fields[0]
 
 	if cmd == "quit" || cmd == "exit" || cmd == "q" {
-		gs.Status = gamestate.STATUS_QUIT
+		gs.SetStatus(gamestate.STATUS_QUIT)
+	} else if cmd == "help" && gs.CanReview() {
+		gs.SetMessage(reviewHelpMsg)
 	} else if cmd == "help" {
-		gs.Message = "Enter a move using algebraic notation. Or 'quit'."
+		gs.SetMessage(helpMsg)
+	} else if cmd == "save" || cmd == "load" {
+		if err := saveOrLoad(gs, cmd, strings.Join(fields[1:], " ")); err != nil {
+			gs.SetMessage(err.Error())
+		}
+	} else if cmd == "setup" {
+		if err := setupPosition(gs, strings.Join(fields[1:], " ")); err != nil {
+			gs.SetMessage(err.Error())
+		}
+	} else if cmd == "pause" {
+		if err := gs.Pause(); err != nil {
+			gs.SetMessage(err.Error())
+		}
+	} else if cmd == "resume" {
+		if err := gs.Resume(); err != nil {
+			gs.SetMessage(err.Error())
+		}
+	} else if cmd == "offer" || cmd == "draw" || cmd == "accept" || cmd == "decline" || cmd == "claim" || cmd == "resign" {
+		if err := processResultCommand(gs, cmd, fields[1:]); err != nil {
+			gs.SetMessage(err.Error())
+		}
+	} else if gs.CanReview() {
+		ProcessReviewCommand(gs, cmd, fields[1:])
+	} else if cmd == "hint" {
+		if err := input.showHint(gs, strings.Join(fields[1:], "")); err != nil {
+			gs.SetMessage(err.Error())
+		}
+	} else if gs.Status == gamestate.STATUS_PAUSED {
+		gs.SetMessage(string(gamestate.STATUS_PAUSED))
 	} else if gs.ActivePlayerIsHuman() && gs.Status == gamestate.STATUS_PLAYING {
-		// Assume that we are issuing a move
-		gs.ParseAndExecuteAlgebraicNotation(cmd)
+		// Assume that we are issuing a move, or answering which piece an
+		// ambiguous move was meant for
+		if !input.chooseCandidate(gs, cmd) && !input.choosePromotion(gs, cmd) {
+			input.playMove(gs, cmd)
+		}
+	}
+}
+
+// Draw offers, claims and resignations are made by the human player, or by the
+// player to move when both are human
+func processResultCommand(gs *gamestate.GameState, cmd string, args []string) error {
+	human, ok := humanPlayer(gs)
+	if !ok {
+		return fmt.Errorf("Only a human player can %s", cmd)
+	}
+
+	switch cmd {
+	case "offer", "draw":
+		if gs.ActiveColor != human {
+			return fmt.Errorf("Offer a draw on your turn")
+		}
+		return gs.OfferDraw()
+	case "accept", "decline":
+		if gs.DrawOfferedBy == human {
+			return fmt.Errorf("Your opponent has to answer your draw offer")
+		}
+		if cmd == "accept" {
+			return gs.AcceptDraw()
+		}
+		return gs.DeclineDraw()
+	case "claim":
+		if gs.ActiveColor != human {
+			return fmt.Errorf("Claim a draw on your turn")
+		}
+		return gs.ClaimDraw(strings.Join(args, ""))
+	default:
+		return gs.Resign(human)
+	}
+}
+
+func humanPlayer(gs *gamestate.GameState) (piece.Color, bool) {
+	switch {
+	case gs.ActivePlayerIsHuman():
+		return gs.ActiveColor, true
+	case gs.WhiteIsHuman:
+		return piece.WHITE, true
+	case gs.BlackIsHuman:
+		return piece.BLACK, true
+	}
+	return 0, false
+}
+
+func ProcessReviewCommand(gs *gamestate.GameState, cmd string, args []string) {
+	var err error
+	switch cmd {
+	case "first":
+		err = gs.ReviewFirst()
+	case "prev", "p":
+		err = gs.ReviewPrev()
+	case "next", "n":
+		err = gs.ReviewNext()
+	case "last":
+		err = gs.ReviewLast()
+	case "goto":
+		if len(args) != 1 {
+			err = fmt.Errorf("Usage: goto <move number>, e.g. 'goto 12' or 'goto 12...'")
+			break
+		}
+		black := strings.HasSuffix(args[0], "...")
+		var moveNum int
+		moveNum, err = strconv.Atoi(strings.TrimRight(args[0], "."))
+		if err == nil {
+			err = gs.ReviewGotoMove(moveNum, black)
+		} else {
+			err = fmt.Errorf("'%s' is not a move number", args[0])
+		}
+	case "branch":
+		err = gs.BranchFromReview()
+	default:
+		err = fmt.Errorf("Unknown command '%s'. %s", cmd, reviewHelpMsg)
+	}
+
+	if err != nil {
+		gs.SetMessage(err.Error())
 	}
 }
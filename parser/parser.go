@@ -2,210 +2,400 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Jesselli/tchess/board"
 	"github.com/Jesselli/tchess/piece"
 )
 
-var PieceChars = map[byte]piece.Type{
-	'R': piece.ROOK,
-	'N': piece.KNIGHT,
-	'B': piece.BISHOP,
-	'Q': piece.QUEEN,
-	'K': piece.KING,
-}
-
-type TokenType int
-
-const (
-	TOKEN_PIECE        = 0
-	TOKEN_CAPTURE      = 1
-	TOKEN_SQUARE       = 2
-	TOKEN_PROMOTE      = 3
-	TOKEN_SHORT_CASTLE = 4
-	TOKEN_LONG_CASTLE  = 5
-	TOKEN_RANK         = 6
-	TOKEN_FILE         = 7
-)
+// Error points to the part of a move that could not be read
+type Error struct {
+	Notation string
+	Column   int // Index of the offending character, -1 if unknown
+	Msg      string
+}
 
-type Token struct {
-	Type    TokenType
-	Literal string
+func (e *Error) Error() string {
+	if e.Notation == "" {
+		return e.Msg
+	}
+	if e.Column < 0 {
+		return fmt.Sprintf("Invalid move '%s': %s", e.Notation, e.Msg)
+	}
+	return fmt.Sprintf("Invalid move '%s' at column %d: %s", e.Notation, e.Column+1, e.Msg)
 }
 
+// Reads a move in standard algebraic notation (Nbxd7, exd6 e.p., e8=Q+, O-O),
+// long algebraic notation (Ng1-f3, e2xd3) or the notation of UCI (e7e8q).
+// Castling may be written with O, o or 0, and a move may end in a check or
+// mate sign and an annotation such as !? that are ignored. Pieces are written
+// with the letters of piece.ActiveLanguage, e.g. Sf3 in German, or as
+// figurines (♘f3, e8=♕). Pieces are dropped in Crazyhouse with an @ (N@f3).
+//
+// The grammar, where the suffix may follow every kind of move:
+//
+//	move       = castle | drop | pieceMove | pawnMove | lanMove
+//	castle     = ( "O-O" | "O-O-O" ) with O, o or 0
+//	pieceMove  = PIECE [ FILE ] [ RANK ] [ "x" | "-" ] square [ promotion ]
+//	pawnMove   = square [ promotion ] | FILE [ "x" ] square [ promotion ] [ "e.p." ]
+//	lanMove    = square [ "x" | "-" ] square [ promotion ]
+//	drop       = [ PIECE | "P" ] "@" square
+//	promotion  = [ "=" | "/" ] PIECE
+//	suffix     = [ "+" | "++" | "#" ] [ "!" | "?" | "!!" | "??" | "!?" | "?!" ]
 func AlgebraicNotationToMove(notation string) (board.Move, error) {
-	tokens, err := tokenizeCommand(notation)
+	notation = strings.TrimSpace(notation)
+	p := moveParser{typed: notation}
+	p.notation, p.figurines = replaceFigurines(notation)
+	if p.notation == "" {
+		return board.Move{}, &Error{Column: -1, Msg: "No input."}
+	}
+
+	mv, err := p.move()
+	if err == nil {
+		err = p.suffix()
+	}
 	if err != nil {
 		return board.Move{}, err
 	}
-	return parseTokensIntoMove(tokens)
+	return mv, nil
 }
 
-func tokenizeCommand(command string) ([]Token, error) {
-	var idx int = 0
-	tokens := []Token{}
+type moveParser struct {
+	notation  string // What was typed, with letters instead of figurines
+	typed     string
+	figurines map[int]piece.Type // Pieces of the figurines by their index in notation
+	pos       int
+}
 
-	var err error
-	for idx < len(command) {
-		new_idx, token, tokenErr := getNextToken(idx, command)
-		if tokenErr != nil {
-			err = tokenErr
-			break
+func (p *moveParser) errorf(format string, args ...any) *Error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *moveParser) errorAt(column int, format string, args ...any) *Error {
+	return &Error{Notation: p.typed, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// PAWN_FIGURINE stands in for a pawn figurine, which has no letter
+const PAWN_FIGURINE = 'p'
+
+// Writes figurines as the letters of the active language, and pawn figurines
+// as PAWN_FIGURINE. Every figurine takes one character like a letter, so the
+// columns of errors still match. The pieces are also returned by index, as
+// a letter may be read differently before an @.
+func replaceFigurines(notation string) (string, map[int]piece.Type) {
+	var sb strings.Builder
+	figurines := make(map[int]piece.Type)
+	for _, r := range notation {
+		t, ok := piece.FigurineType(r)
+		switch {
+		case !ok:
+			sb.WriteRune(r)
+			continue
+		case t == piece.PAWN:
+			figurines[sb.Len()] = t
+			sb.WriteByte(PAWN_FIGURINE)
+		default:
+			figurines[sb.Len()] = t
+			sb.WriteByte(piece.ActiveLanguage.Letter(t))
 		}
-		tokens = append(tokens, token)
-		idx = new_idx
 	}
+	return sb.String(), figurines
+}
+
+func (p *moveParser) atEnd() bool {
+	return p.pos >= len(p.notation)
+}
+
+// Returns the current character, or 0 at the end
+func (p *moveParser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+	return p.notation[p.pos]
+}
+
+// Moves past the current character if it is one of chars
+func (p *moveParser) accept(chars string) (byte, bool) {
+	c := p.peek()
+	if c != 0 && strings.IndexByte(chars, c) >= 0 {
+		p.pos++
+		return c, true
+	}
+	return 0, false
+}
+
+// Moves past the given text if the notation continues with it
+func (p *moveParser) acceptText(text string) bool {
+	if strings.HasPrefix(p.notation[p.pos:], text) {
+		p.pos += len(text)
+		return true
+	}
+	return false
+}
+
+func isFile(c byte) bool {
+	return 'a' <= c && c <= 'h'
+}
+
+func isRank(c byte) bool {
+	return '1' <= c && c <= '8'
+}
 
-	return tokens, err
+func (p *moveParser) move() (board.Move, error) {
+	if p.figurines[p.pos] == piece.PAWN {
+		// Pawn moves are written without a piece
+		p.pos++
+	}
+	c := p.peek()
+	switch {
+	case c == 'O' || c == 'o' || c == '0':
+		return p.castle()
+	case c == '@' || (c != 0 && strings.HasPrefix(p.notation[p.pos+1:], "@")):
+		return p.drop()
+	case piece.ActiveLanguage.PieceFromLetter(c) != piece.NONE:
+		return p.pieceMove()
+	case isFile(c):
+		return p.pawnOrLANMove()
+	}
+	return board.Move{}, p.errorf("Expected a piece, a square or castling, found '%c'", c)
 }
 
-func getNextToken(idx int, command string) (int, Token, error) {
-	ch := command[idx]
-	token := Token{}
-	var err error
-	if 'a' <= ch && ch <= 'h' {
-		var peekCh byte = 0
-		if len(command) > idx+1 {
-			peekCh = command[idx+1]
+func (p *moveParser) castle() (board.Move, error) {
+	mv := board.Move{Piece: piece.KING, Castle: true, TrgFile: 'g'}
+	letter := p.peek()
+	p.pos++
+	if !p.acceptText("-") || !p.acceptText(string(letter)) {
+		return mv, p.errorf("Expected O-O or O-O-O")
+	}
+	if p.acceptText("-") {
+		if !p.acceptText(string(letter)) {
+			return mv, p.errorf("Expected O-O-O")
 		}
+		mv.TrgFile = 'c'
+	}
+	return mv, nil
+}
 
-		if '1' <= peekCh && peekCh <= '8' {
-			token = Token{TOKEN_SQUARE, command[idx : idx+2]}
-			idx += 1
-		} else {
-			token = Token{TOKEN_FILE, string(ch)}
+// Reads a drop from the pocket. Pawns are dropped without a letter (@e4), or
+// with a P as in the notation of UCI (P@e4).
+func (p *moveParser) drop() (board.Move, error) {
+	mv := board.Move{Piece: piece.PAWN, Drop: true}
+	if c := p.peek(); c != '@' {
+		mv.Piece = dropPiece(c)
+		if t, ok := p.figurines[p.pos]; ok {
+			mv.Piece = t
 		}
-	} else if '1' <= ch && ch <= '8' {
-		token = Token{TOKEN_RANK, string(ch)}
-	} else if ch == 'x' {
-		token = Token{TOKEN_CAPTURE, "x"}
-	} else if ch == '=' {
-		token = Token{TOKEN_PROMOTE, "="}
-	} else if ch == 'o' {
-		if len(command) > idx+4 && command[idx:idx+5] == "o-o-o" {
-			token = Token{TOKEN_LONG_CASTLE, command[idx : idx+5]}
-			idx += 4
-		} else if len(command) > idx+2 && command[idx:idx+3] == "o-o" {
-			token = Token{TOKEN_SHORT_CASTLE, command[idx : idx+3]}
-			idx += 2
+		if mv.Piece == piece.NONE || mv.Piece == piece.KING {
+			return mv, p.errorf("Expected the piece to drop, found '%c'", c)
 		}
-	} else if _, ok := PieceChars[ch]; ok {
-		token = Token{TOKEN_PIECE, string(command[idx])}
+		p.pos++
+	}
+	p.pos++
+
+	column := p.pos
+	to := p.coordinates()
+	if len(to) != 2 || !isFile(to[0]) || !isRank(to[1]) {
+		return mv, p.errorAt(column, "Expected a square such as e4, found '%s'", to)
+	}
+	mv.SetTrgFromAlphaNum(to)
+	return mv, nil
+}
+
+// P is always the pawn of UCI, even where it names another piece like the
+// Dutch knight (paard). Other letters of the active language come first, then
+// the English ones, so the Dutch drop a knight with N@f3.
+func dropPiece(c byte) piece.Type {
+	if c == 'P' {
+		return piece.PAWN
+	}
+	if t := piece.ActiveLanguage.PieceFromLetter(c); t != piece.NONE {
+		return t
+	}
+	return piece.Languages["en"].PieceFromLetter(c)
+}
+
+// Reads a run of files and ranks, such as the "bd7" of Nbd7. A square never
+// ends in a file, so a file after a rank is left for what follows, like the
+// promotion of e7e8b or the en passant mark of exd6ep.
+func (p *moveParser) coordinates() string {
+	start := p.pos
+	for isFile(p.peek()) || isRank(p.peek()) {
+		p.pos++
+	}
+	if p.pos-start >= 2 && isFile(p.notation[p.pos-1]) && isRank(p.notation[p.pos-2]) {
+		p.pos--
+	}
+	return p.notation[start:p.pos]
+}
+
+// Reads the coordinates before and after an optional capture sign. Without a
+// sign the target square is the end of the only run.
+func (p *moveParser) squares() (from string, capture bool, to string, err error) {
+	from = p.coordinates()
+	if sep, ok := p.accept("x-:"); ok {
+		capture = sep != '-'
+		to = p.coordinates()
 	} else {
-		err = fmt.Errorf("Unrecognized notation '%c'", ch)
+		split := len(from) - 2
+		if split < 0 {
+			split = 0
+		}
+		from, to = from[:split], from[split:]
 	}
 
-	idx += 1
+	switch {
+	case to == "":
+		err = p.errorf("Expected the target square")
+	case len(to) == 1 && isFile(to[0]):
+		err = p.errorf("Expected the rank of the target square")
+	case len(to) != 2 || !isFile(to[0]) || !isRank(to[1]):
+		err = p.errorAt(p.pos-len(to), "Expected a square such as e4, found '%s'", to)
+	}
+	return from, capture, to, err
+}
 
-	return idx, token, err
+// Checks that the characters before the target square can tell pieces apart,
+// e.g. the b of Nbd7, the 1 of R1a3 or the h4 of Qh4xe1
+func (p *moveParser) disambiguation(mv *board.Move, from string, column int) error {
+	switch {
+	case from == "":
+	case len(from) == 1 && isFile(from[0]):
+		mv.SrcFile = from[0]
+	case len(from) == 1 && isRank(from[0]):
+		mv.SrcRank = from[0]
+	case len(from) == 2 && isFile(from[0]) && isRank(from[1]):
+		mv.SetSrcFromAlphaNum(from)
+	default:
+		return p.errorAt(column, "'%s' is neither a file, a rank nor a square", from)
+	}
+	return nil
 }
 
-func parseTokensIntoMove(tokens []Token) (board.Move, error) {
-	// TODO: Add assertions and errors
-	mv := board.Move{}
-	var err error
+func (p *moveParser) pieceMove() (board.Move, error) {
+	mv := board.Move{Piece: piece.ActiveLanguage.PieceFromLetter(p.peek())}
+	p.pos++
 
-	if len(tokens) == 0 {
-		err = fmt.Errorf("No input.")
+	column := p.pos
+	from, capture, to, err := p.squares()
+	if err != nil {
+		return mv, err
+	}
+	err = p.disambiguation(&mv, from, column)
+	if err != nil {
+		return mv, err
 	}
+	mv.Capture = capture
+	mv.SetTrgFromAlphaNum(to)
 
-	if len(tokens) == 1 {
-		token := tokens[0]
-		if token.Type == TOKEN_SQUARE {
-			// Pawn move (e4)
-			mv.Piece = piece.PAWN
-			mv.SrcFile = token.Literal[0]
-			mv.SetTrgFromAlphaNum(token.Literal)
-		} else if token.Type == TOKEN_SHORT_CASTLE {
-			// Short castle (o-o)
-			mv.Piece = piece.KING
-			mv.SrcFile = 'e'
-			mv.TrgFile = 'g'
-		} else if token.Type == TOKEN_LONG_CASTLE {
-			// Long castle (o-o-o)
-			mv.Piece = piece.KING
-			mv.SrcFile = 'e'
-			mv.TrgFile = 'c'
-		}
+	if p.promotionAhead() {
+		return mv, p.errorf("Only pawns can promote")
 	}
+	return mv, nil
+}
 
-	if len(tokens) == 2 {
-		token1 := tokens[0]
-		token2 := tokens[1]
-		if token1.Type == TOKEN_PIECE &&
-			token2.Type == TOKEN_SQUARE {
-			// Piece move (Ra6)
-			mv.Piece = PieceChars[token1.Literal[0]]
-			mv.SetTrgFromAlphaNum(token2.Literal)
-		} else if token1.Type == TOKEN_SQUARE &&
-			token2.Type == TOKEN_SQUARE {
-			// Long algebraic notation (e2e4)
-			mv.SetSrcFromAlphaNum(token1.Literal)
-			mv.SetTrgFromAlphaNum(token2.Literal)
-		} else if token1.Type == TOKEN_SQUARE &&
-			token2.Type == TOKEN_PIECE {
-			// Pawn promotion (e8Q)
-			mv.Piece = piece.PAWN
-			mv.SetTrgFromAlphaNum(token1.Literal)
-			mv.Promote = PieceChars[token2.Literal[0]]
-		}
+func (p *moveParser) pawnOrLANMove() (board.Move, error) {
+	var mv board.Move
+	column := p.pos
+	from, capture, to, err := p.squares()
+	if err != nil {
+		return mv, err
 	}
+	mv.Capture = capture
+	mv.SetTrgFromAlphaNum(to)
 
-	if len(tokens) == 3 {
-		token1 := tokens[0]
-		token2 := tokens[1]
-		token3 := tokens[2]
-		if token3.Type == TOKEN_SQUARE {
-			mv.SetTrgFromAlphaNum(token3.Literal)
-
-			if token1.Type == TOKEN_FILE {
-				// Pawn captures square (exf6)
-				mv.Piece = piece.PAWN
-				mv.SrcFile = token1.Literal[0]
-			} else if token1.Type == TOKEN_PIECE {
-				// Piece captures square (Nxf3)
-				mv.Piece = PieceChars[token1.Literal[0]]
-			}
-
-			if token2.Type == TOKEN_RANK {
-				// Disambiguate -- Rdf8
-				mv.SrcRank = token2.Literal[0]
-			} else if token2.Type == TOKEN_FILE {
-				// Disambiguate -- R1a3
-				mv.SrcFile = token2.Literal[0]
-			}
-		} else if token3.Type == TOKEN_PIECE {
-			mv.Piece = piece.PAWN
-			if token1.Type == TOKEN_SQUARE &&
-				token2.Type == TOKEN_SQUARE {
-				// Long notation pawn promotion (e7e8Q)
-				mv.SetSrcFromAlphaNum(token1.Literal)
-				mv.SetTrgFromAlphaNum(token2.Literal)
-				mv.Promote = PieceChars[token3.Literal[0]]
-			} else if token1.Type == TOKEN_SQUARE {
-				// Pawn promotion (e8=Q)
-				mv.SetTrgFromAlphaNum(token1.Literal)
-				mv.Promote = PieceChars[token3.Literal[0]]
-			}
+	switch {
+	case from == "" && !capture:
+		// Pawn push (e4). A promotion (e8=Q) leaves the file open.
+		mv.Piece = piece.PAWN
+		if !p.promotionAhead() {
+			mv.SrcFile = to[0]
+		}
+	case len(from) == 1 && isFile(from[0]):
+		// Pawn capture (exd5, or ed5 without the sign)
+		if from[0] == to[0] || board.Abs(int(from[0])-int(to[0])) != 1 {
+			return mv, p.errorAt(column, "A pawn on the %c-file can not capture on %s", from[0], to)
 		}
+		mv.Piece = piece.PAWN
+		mv.SrcFile = from[0]
+		mv.Capture = true
+	case len(from) == 2 && isFile(from[0]) && isRank(from[1]):
+		// Long algebraic notation (e2e4), the piece is found on the board
+		mv.SetSrcFromAlphaNum(from)
+	default:
+		return mv, p.errorAt(column, "Expected a square or the file of a pawn, found '%s'", from)
 	}
 
-	if len(tokens) == 4 {
-		token1 := tokens[0]
-		token2 := tokens[1]
-		token4 := tokens[3]
+	if p.promotionAhead() {
+		err = p.promotion(&mv)
+	} else if mv.Piece == piece.PAWN && mv.Capture {
+		// The mark is only skipped. The board has no en passant captures, so
+		// it rejects the move as illegal rather than the parser.
+		p.enPassant()
+	}
+	return mv, err
+}
+
+// Returns true if a promotion follows
+func (p *moveParser) promotionAhead() bool {
+	c := p.peek()
+	return c == '=' || c == '/' || promotionPiece(c) != piece.NONE
+}
 
-		mv.Piece = PieceChars[token1.Literal[0]]
-		mv.SetTrgFromAlphaNum(token4.Literal)
+// Upper case letters are those of the active language, lower case ones are
+// English as in the notation of UCI
+func promotionPiece(c byte) piece.Type {
+	if 'a' <= c && c <= 'z' {
+		return piece.Languages["en"].PieceFromLetter(c - 'a' + 'A')
+	}
+	return piece.ActiveLanguage.PieceFromLetter(c)
+}
 
-		if token2.Type == TOKEN_SQUARE {
-			mv.SetSrcFromAlphaNum(token2.Literal)
-		} else if token2.Type == TOKEN_RANK {
-			mv.SrcRank = token2.Literal[0]
-		} else if token2.Type == TOKEN_FILE {
-			mv.SrcFile = token2.Literal[0]
+// Reads the piece a pawn promotes to. UCI writes it in lower case (e7e8q).
+func (p *moveParser) promotion(mv *board.Move) error {
+	if mv.TrgRank != '1' && mv.TrgRank != '8' {
+		return p.errorf("Only a pawn reaching the last rank can promote")
+	}
+	_, sign := p.accept("=/")
+	promote := promotionPiece(p.peek())
+	if promote == piece.NONE {
+		if sign {
+			return p.errorf("Expected the piece to promote to after '%c'", p.notation[p.pos-1])
 		}
+		return p.errorf("Expected the piece to promote to")
 	}
+	p.pos++
+	mv.Piece = piece.PAWN
+	mv.Promote = promote
+	return nil
+}
 
-	return mv, err
+// Moves past an "e.p." or "ep" mark, which may be separated by a space
+func (p *moveParser) enPassant() bool {
+	start := p.pos
+	p.accept(" ")
+	if p.acceptText("e.p.") || p.acceptText("ep") {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+// Reads the check or mate sign and the annotation at the end of a move
+func (p *moveParser) suffix() error {
+	if !p.acceptText("++") {
+		p.accept("+#")
+	}
+	for _, annotation := range []string{"!!", "??", "!?", "?!", "!", "?"} {
+		if p.acceptText(annotation) {
+			break
+		}
+	}
+
+	if p.atEnd() {
+		return nil
+	}
+	if strings.HasPrefix(p.notation[p.pos:], " e.p.") || strings.HasPrefix(p.notation[p.pos:], "e.p.") {
+		return p.errorf("Only a pawn capture can be en passant")
+	}
+	return p.errorf("Unexpected '%s' after the move", p.notation[p.pos:])
 }
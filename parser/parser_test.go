@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/Jesselli/tchess/board"
@@ -32,7 +34,7 @@ func TestShortCastle(t *testing.T) {
 	cmd := "o-o"
 	expectedMv := board.Move{}
 	expectedMv.Piece = piece.KING
-	expectedMv.SrcFile = 'e'
+	expectedMv.Castle = true
 	expectedMv.TrgFile = 'g'
 	actualMv, err := AlgebraicNotationToMove(cmd)
 	checkResult(cmd, expectedMv, actualMv, err, t)
@@ -42,7 +44,7 @@ func TestLongCastle(t *testing.T) {
 	cmd := "o-o-o"
 	expectedMv := board.Move{}
 	expectedMv.Piece = piece.KING
-	expectedMv.SrcFile = 'e'
+	expectedMv.Castle = true
 	expectedMv.TrgFile = 'c'
 	actualMv, err := AlgebraicNotationToMove(cmd)
 	checkResult(cmd, expectedMv, actualMv, err, t)
@@ -169,3 +171,170 @@ func TestFullDisambiguateCapture(t *testing.T) {
 	actualMv, err := AlgebraicNotationToMove(cmd)
 	checkResult(cmd, expectedMv, actualMv, err, t)
 }
+
+func TestNotations(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want board.Move
+	}{
+		{"O-O", board.Move{Piece: piece.KING, Castle: true, TrgFile: 'g'}},
+		{"0-0-0", board.Move{Piece: piece.KING, Castle: true, TrgFile: 'c'}},
+		{"O-O+", board.Move{Piece: piece.KING, Castle: true, TrgFile: 'g'}},
+		{"Nbxd7", board.Move{Piece: piece.KNIGHT, SrcFile: 'b', TrgFile: 'd', TrgRank: '7'}},
+		{"Nf3!?", board.Move{Piece: piece.KNIGHT, TrgFile: 'f', TrgRank: '3'}},
+		{"Qxf7#", board.Move{Piece: piece.QUEEN, TrgFile: 'f', TrgRank: '7'}},
+		{"Ng1-f3", board.Move{Piece: piece.KNIGHT, SrcFile: 'g', SrcRank: '1', TrgFile: 'f', TrgRank: '3'}},
+		{"e4!!", board.Move{Piece: piece.PAWN, SrcFile: 'e', TrgFile: 'e', TrgRank: '4'}},
+		{"exd6 e.p.", board.Move{Piece: piece.PAWN, SrcFile: 'e', TrgFile: 'd', TrgRank: '6'}},
+		{"exd6ep+", board.Move{Piece: piece.PAWN, SrcFile: 'e', TrgFile: 'd', TrgRank: '6'}},
+		{"ed5", board.Move{Piece: piece.PAWN, SrcFile: 'e', TrgFile: 'd', TrgRank: '5'}},
+		{"e8=Q+", board.Move{Piece: piece.PAWN, TrgFile: 'e', TrgRank: '8', Promote: piece.QUEEN}},
+		{"dxe1=N", board.Move{Piece: piece.PAWN, SrcFile: 'd', TrgFile: 'e', TrgRank: '1', Promote: piece.KNIGHT}},
+		{"e2-e4", board.Move{SrcFile: 'e', SrcRank: '2', TrgFile: 'e', TrgRank: '4'}},
+		{"e7e8q", board.Move{Piece: piece.PAWN, SrcFile: 'e', SrcRank: '7', TrgFile: 'e', TrgRank: '8', Promote: piece.QUEEN}},
+		{"b7b8b", board.Move{Piece: piece.PAWN, SrcFile: 'b', SrcRank: '7', TrgFile: 'b', TrgRank: '8', Promote: piece.BISHOP}},
+		{"a2a1k", board.Move{Piece: piece.PAWN, SrcFile: 'a', SrcRank: '2', TrgFile: 'a', TrgRank: '1', Promote: piece.KING}},
+		{"N@f3", board.Move{Piece: piece.KNIGHT, Drop: true, TrgFile: 'f', TrgRank: '3'}},
+		{"P@e4+", board.Move{Piece: piece.PAWN, Drop: true, TrgFile: 'e', TrgRank: '4'}},
+		{"@e4", board.Move{Piece: piece.PAWN, Drop: true, TrgFile: 'e', TrgRank: '4'}},
+	}
+
+	for _, tt := range tests {
+		got, err := AlgebraicNotationToMove(tt.cmd)
+		if err != nil {
+			t.Errorf("%s: %v", tt.cmd, err)
+		} else if !got.Equals(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		cmd    string
+		column int
+	}{
+		{"hello", 0},
+		{"Nz3", 1},
+		{"N", 1},
+		{"Nf", 2},
+		{"exd", 3},
+		{"exg5", 0},
+		{"O-0", 2},
+		{"e4=Q", 2},
+		{"e8=", 3},
+		{"Nf3=Q", 3},
+		{"Nf3 e.p.", 3},
+		{"e4 e5", 2},
+		{"Qd1d2d3", 1},
+		{"K@e4", 0},
+		{"N@z3", 2},
+	}
+
+	for _, tt := range tests {
+		_, err := AlgebraicNotationToMove(tt.cmd)
+		var parseErr *Error
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: expected a *parser.Error, got %v", tt.cmd, err)
+		} else if parseErr.Column != tt.column {
+			t.Errorf("%s: error at column %d, want %d: %v", tt.cmd, parseErr.Column, tt.column, err)
+		}
+	}
+}
+
+func FuzzAlgebraicNotationToMove(f *testing.F) {
+	for _, seed := range []string{"e4", "Nbxd7", "O-O-O", "exd6 e.p.", "e7e8q", "Qh4xe1+", "R1a3!?", "0-0#", "b8=N"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, cmd string) {
+		mv, err := AlgebraicNotationToMove(cmd)
+		if err != nil {
+			return
+		}
+		castle := mv.Piece == piece.KING && mv.TrgRank == 0
+		if mv.TrgFile < 'a' || mv.TrgFile > 'h' || (!castle && (mv.TrgRank < '1' || mv.TrgRank > '8')) {
+			t.Errorf("%q parsed to the target %c%c", cmd, mv.TrgFile, mv.TrgRank)
+		}
+		if (mv.SrcFile != 0 && (mv.SrcFile < 'a' || mv.SrcFile > 'h')) || (mv.SrcRank != 0 && (mv.SrcRank < '1' || mv.SrcRank > '8')) {
+			t.Errorf("%q parsed to the source %c%c", cmd, mv.SrcFile, mv.SrcRank)
+		}
+		if mv.Promote != piece.NONE && (mv.Piece != piece.PAWN || mv.Promote == piece.PAWN) {
+			t.Errorf("%q parsed to %+v", cmd, mv)
+		}
+	})
+}
+
+func TestLanguages(t *testing.T) {
+	defer func(l piece.Language) { piece.ActiveLanguage = l }(piece.ActiveLanguage)
+	piece.ActiveLanguage = piece.Languages["de"]
+
+	tests := []struct {
+		cmd  string
+		want board.Move
+	}{
+		{"Sf3", board.Move{Piece: piece.KNIGHT, TrgFile: 'f', TrgRank: '3'}},
+		{"Dxd7+", board.Move{Piece: piece.QUEEN, TrgFile: 'd', TrgRank: '7'}},
+		{"Tad1", board.Move{Piece: piece.ROOK, SrcFile: 'a', TrgFile: 'd', TrgRank: '1'}},
+		{"e8=L", board.Move{Piece: piece.PAWN, TrgFile: 'e', TrgRank: '8', Promote: piece.BISHOP}},
+		{"e7e8q", board.Move{Piece: piece.PAWN, SrcFile: 'e', SrcRank: '7', TrgFile: 'e', TrgRank: '8', Promote: piece.QUEEN}},
+	}
+	for _, tt := range tests {
+		got, err := AlgebraicNotationToMove(tt.cmd)
+		if err != nil {
+			t.Errorf("%s: %v", tt.cmd, err)
+		} else if !got.Equals(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.cmd, got, tt.want)
+		}
+	}
+
+	if _, err := AlgebraicNotationToMove("Nf3"); err == nil {
+		t.Error("The English Nf3 was accepted in German")
+	}
+
+	// The Dutch knight is a P, which still drops a pawn
+	piece.ActiveLanguage = piece.Languages["nl"]
+	for cmd, want := range map[string]piece.Type{"P@e4": piece.PAWN, "N@e4": piece.KNIGHT, "Pf3": piece.KNIGHT} {
+		if got, err := AlgebraicNotationToMove(cmd); err != nil || got.Piece != want {
+			t.Errorf("%s in Dutch: got %+v, %v", cmd, got, err)
+		}
+	}
+}
+
+func TestFigurines(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want board.Move
+	}{
+		{"♘f3", board.Move{Piece: piece.KNIGHT, TrgFile: 'f', TrgRank: '3'}},
+		{"♕xd7+", board.Move{Piece: piece.QUEEN, TrgFile: 'd', TrgRank: '7'}},
+		{"♜ad1", board.Move{Piece: piece.ROOK, SrcFile: 'a', TrgFile: 'd', TrgRank: '1'}},
+		{"♙e4", board.Move{Piece: piece.PAWN, SrcFile: 'e', TrgFile: 'e', TrgRank: '4'}},
+		{"e8=♗", board.Move{Piece: piece.PAWN, TrgFile: 'e', TrgRank: '8', Promote: piece.BISHOP}},
+	}
+	for _, tt := range tests {
+		got, err := AlgebraicNotationToMove(tt.cmd)
+		if err != nil {
+			t.Errorf("%s: %v", tt.cmd, err)
+		} else if !got.Equals(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.cmd, got, tt.want)
+		}
+	}
+
+	for cmd, column := range map[string]int{"♘z3": 1, "♙e4z": 3, "♙z4": 1, "♙": 1} {
+		var perr *Error
+		_, err := AlgebraicNotationToMove(cmd)
+		if !errors.As(err, &perr) || perr.Notation != cmd || perr.Column != column {
+			t.Errorf("%s: expected an error at column %d, got %v", cmd, column+1, err)
+		}
+	}
+
+	// The Dutch knight figurine is written as a P, which is still a knight
+	// when dropped
+	defer func(l piece.Language) { piece.ActiveLanguage = l }(piece.ActiveLanguage)
+	piece.ActiveLanguage = piece.Languages["nl"]
+	for cmd, want := range map[string]piece.Type{"♘@f3": piece.KNIGHT, "♙@f3": piece.PAWN, "♘f3": piece.KNIGHT} {
+		if got, err := AlgebraicNotationToMove(cmd); err != nil || got.Piece != want || got.Drop != strings.Contains(cmd, "@") {
+			t.Errorf("%s in Dutch: got %+v, %v", cmd, got, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package piece
+
+import "strings"
+
+// Language holds the letters that stand for the pieces in algebraic notation.
+// Pawns have no letter.
+type Language struct {
+	Name    string
+	Letters map[Type]byte
+}
+
+var Languages = map[string]Language{
+	"en": {"English", map[Type]byte{KING: 'K', QUEEN: 'Q', ROOK: 'R', BISHOP: 'B', KNIGHT: 'N'}},
+	"de": {"German", map[Type]byte{KING: 'K', QUEEN: 'D', ROOK: 'T', BISHOP: 'L', KNIGHT: 'S'}},
+	"fr": {"French", map[Type]byte{KING: 'R', QUEEN: 'D', ROOK: 'T', BISHOP: 'F', KNIGHT: 'C'}},
+	"es": {"Spanish", map[Type]byte{KING: 'R', QUEEN: 'D', ROOK: 'T', BISHOP: 'A', KNIGHT: 'C'}},
+	"it": {"Italian", map[Type]byte{KING: 'R', QUEEN: 'D', ROOK: 'T', BISHOP: 'A', KNIGHT: 'C'}},
+	"pt": {"Portuguese", map[Type]byte{KING: 'R', QUEEN: 'D', ROOK: 'T', BISHOP: 'B', KNIGHT: 'C'}},
+	"nl": {"Dutch", map[Type]byte{KING: 'K', QUEEN: 'D', ROOK: 'T', BISHOP: 'L', KNIGHT: 'P'}},
+	"sv": {"Swedish", map[Type]byte{KING: 'K', QUEEN: 'D', ROOK: 'T', BISHOP: 'L', KNIGHT: 'S'}},
+	"pl": {"Polish", map[Type]byte{KING: 'K', QUEEN: 'H', ROOK: 'W', BISHOP: 'G', KNIGHT: 'S'}},
+	"cs": {"Czech", map[Type]byte{KING: 'K', QUEEN: 'D', ROOK: 'V', BISHOP: 'S', KNIGHT: 'J'}},
+}
+
+var LanguageCodes = []string{"en", "de", "fr", "es", "it", "pt", "nl", "sv", "pl", "cs"}
+
+// Language of the moves the players type and read
+var ActiveLanguage = Languages["en"]
+
+// Glyph set of the figurines that replace the piece letters in displayed moves,
+// e.g. ♘f3. Empty to use the letters of ActiveLanguage.
+var MoveFigurines = ""
+
+// Returns the piece the letter stands for, or NONE
+func (l Language) PieceFromLetter(c byte) Type {
+	for t, letter := range l.Letters {
+		if letter == c {
+			return t
+		}
+	}
+	return NONE
+}
+
+// Returns the letter of the piece, or 0 for a pawn
+func (l Language) Letter(t Type) byte {
+	return l.Letters[t]
+}
+
+// Lists the letters of the pieces a pawn can promote to, e.g. "Q, R, B or N"
+func (l Language) PromotionLetters() string {
+	letters := []string{string(l.Letters[QUEEN]), string(l.Letters[ROOK]), string(l.Letters[BISHOP]), string(l.Letters[KNIGHT])}
+	return strings.Join(letters[:3], ", ") + " or " + letters[3]
+}
+
+// Returns the symbol of the piece in a displayed move: its figurine if
+// MoveFigurines is set, otherwise its letter. Pawns have no symbol.
+func MoveSymbol(t Type) string {
+	if t == PAWN || t == NONE {
+		return ""
+	}
+	if MoveFigurines != "" {
+		return string(Piece{Type: t, Color: WHITE}.Glyph(MoveFigurines))
+	}
+	return string(ActiveLanguage.Letter(t))
+}
+
+// Returns the piece a figurine such as ♘ or ♞ stands for
+func FigurineType(r rune) (Type, bool) {
+	for _, runes := range []map[Type]rune{PieceRunesFilled, PieceRunesOutlined, PieceRunesNerdFont} {
+		for t, figurine := range runes {
+			if t != NONE && figurine == r {
+				return t, true
+			}
+		}
+	}
+	return NONE, false
+}
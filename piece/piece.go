@@ -60,6 +60,52 @@ var PieceRunesFilled = map[Type]rune{
 	KING:   '♚',
 }
 
+// Glyphs from the Material Design icons bundled with Nerd Fonts
+var PieceRunesNerdFont = map[Type]rune{
+	NONE:   ' ',
+	PAWN:   '\U000F0859',
+	ROOK:   '\U000F085B',
+	KNIGHT: '\U000F0858',
+	BISHOP: '\U000F085C',
+	QUEEN:  '\U000F085A',
+	KING:   '\U000F0857',
+}
+
+const (
+	GLYPHS_FILLED   = "filled"
+	GLYPHS_OUTLINED = "outlined"
+	GLYPHS_ASCII    = "ascii"
+	GLYPHS_NERD     = "nerd"
+)
+
+var GlyphSetNames = []string{GLYPHS_FILLED, GLYPHS_OUTLINED, GLYPHS_ASCII, GLYPHS_NERD}
+
+// Returns the rune used to draw the piece with the named glyph set. The ASCII
+// set uses FEN letters, so white pieces are upper case and black lower case.
+func (p Piece) Glyph(set string) rune {
+	switch set {
+	case GLYPHS_OUTLINED:
+		return PieceRunesOutlined[p.Type]
+	case GLYPHS_ASCII:
+		if p.Type == NONE {
+			return ' '
+		}
+		return rune(ToFenChar[p])
+	case GLYPHS_NERD:
+		return PieceRunesNerdFont[p.Type]
+	}
+	return PieceRunesFilled[p.Type]
+}
+
+func IsGlyphSet(set string) bool {
+	for _, name := range GlyphSetNames {
+		if name == set {
+			return true
+		}
+	}
+	return false
+}
+
 var ToFenChar = map[Piece]byte{
 	PAWN_W: 'P',
 	PAWN_B: 'p',
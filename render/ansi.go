@@ -0,0 +1,240 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/gamestate"
+	"github.com/Jesselli/tchess/piece"
+	"github.com/Jesselli/tchess/tui"
+)
+
+// ANSI draws the game in the terminal using escape sequences to position the
+// panels computed by the tui layout.
+type ANSI struct {
+	Layout  tui.Layout
+	message string // Shown on the prompt line until the next prompt
+	input   string
+}
+
+func NewANSI() *ANSI {
+	return &ANSI{Layout: tui.ComputeLayout(tui.DefaultWidth, tui.DefaultHeight)}
+}
+
+// Recomputes the layout for the current size of the terminal. The caller has
+// to redraw the game afterwards.
+func (r *ANSI) Resize() {
+	width, height, err := tui.TerminalSize(int(os.Stdout.Fd()))
+	if err != nil || width == 0 || height == 0 {
+		width = tui.DefaultWidth
+		height = tui.DefaultHeight
+	}
+	r.Layout = tui.ComputeLayout(width, height)
+	tui.ClearScreen()
+}
+
+func (r *ANSI) MoveMade(gs *gamestate.GameState, mv board.Move) {
+	r.message = mv.ToStr()
+}
+
+func (r *ANSI) ClockTick(gs *gamestate.GameState) {
+	r.drawClocks(gs)
+}
+
+func (r *ANSI) StatusChanged(gs *gamestate.GameState) {
+	r.Redraw(gs)
+	r.Prompt(gs, r.input)
+}
+
+func (r *ANSI) Message(gs *gamestate.GameState, msg string) {
+	r.message = msg
+}
+
+func (r *ANSI) Redraw(gs *gamestate.GameState) {
+	// While reviewing, show the historical position instead of the live one
+	shown := gs.Shown()
+
+	rotatedBoard := gs.IsBoardRotated()
+	shown.Board.Display(os.Stdout, rotatedBoard, r.Layout)
+	r.drawCaptures(shown, rotatedBoard)
+	r.drawMoveHistory(gs)
+	tui.DrawText(gs.EngineInfo, r.Layout.Engine, tui.GRAY, tui.BLACK)
+	r.drawClocks(gs)
+}
+
+func (r *ANSI) Prompt(gs *gamestate.GameState, input string) {
+	msg := statusLine(gs, r.message)
+	r.message = ""
+	r.input = input
+
+	promptRow := r.Layout.Prompt.Row
+	tui.MoveCursorTo(promptRow, 0)
+	tui.EraseLine()
+	fmt.Fprintf(os.Stdout, "%s", msg)
+	tui.MoveCursorTo(promptRow+1, 0)
+	tui.EraseLine()
+	fmt.Fprintf(os.Stdout, "> %s", input)
+}
+
+// While the game is not being played, the status is shown in front of the
+// message.
+func statusLine(gs *gamestate.GameState, message string) string {
+	msg := message
+	if gs.Status != gamestate.STATUS_PLAYING {
+		msg = string(gs.Status)
+		if gs.Reviewing {
+			msg += " " + gs.ReviewMessage()
+		}
+		if message != "" {
+			msg += " " + message
+		}
+	}
+	return msg
+}
+
+// Formats the time left as mm:ss, h:mm:ss from an hour on, or days and hours
+// from a day on. Partial seconds are rounded up so that 00:00 is only shown
+// once the flag has fallen.
+func FormatClock(d time.Duration) string {
+	sec := 0
+	if d > 0 {
+		sec = int((d + time.Second - 1) / time.Second)
+	}
+	if sec >= 86400 {
+		return fmt.Sprintf("%dd %02dh", sec/86400, sec/3600%24)
+	}
+	if sec >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+	}
+	return fmt.Sprintf("%02d:%02d", sec/60, sec%60)
+}
+
+// Clock text of the given player, dashes if the game is untimed
+func clockText(gs *gamestate.GameState, color piece.Color) string {
+	if gs.Clock.Control.Untimed() {
+		return "--:--"
+	}
+	return FormatClock(gs.TimeRemaining(color))
+}
+
+func (r *ANSI) drawClocks(gs *gamestate.GameState) {
+	wFg := tui.GRAY
+	bFg := tui.GRAY
+	if gs.ActiveColor == piece.WHITE {
+		wFg = tui.WHITE
+	} else {
+		bFg = tui.WHITE
+	}
+
+	wArea := r.Layout.BottomClock
+	bArea := r.Layout.TopClock
+	if gs.IsBoardRotated() {
+		wArea, bArea = bArea, wArea
+	}
+
+	wTimeMsg := clockText(gs, piece.WHITE)
+	bTimeMsg := clockText(gs, piece.BLACK)
+	if r.Layout.Compact {
+		tui.DrawText(wTimeMsg, wArea, wFg, tui.BLACK)
+		tui.DrawText(bTimeMsg, bArea, bFg, tui.BLACK)
+	} else {
+		tui.DrawMsgBox(wTimeMsg, wArea.Col, wArea.Row, wFg, tui.BLACK, true)
+		tui.DrawMsgBox(bTimeMsg, bArea.Col, bArea.Row, bFg, tui.BLACK, true)
+	}
+}
+
+func (r *ANSI) drawCaptures(gs *gamestate.GameState, boardRotated bool) {
+	var wCapSb strings.Builder // Pieces white has captured, or holds in Crazyhouse
+	var bCapSb strings.Builder
+	if gs.Board.Rules().Drops() {
+		for _, v := range pocket(gs.Board, piece.WHITE) {
+			fmt.Fprintf(&wCapSb, "%c", v.Glyph(tui.ActiveTheme.Pieces))
+		}
+		for _, v := range pocket(gs.Board, piece.BLACK) {
+			fmt.Fprintf(&bCapSb, "%c", v.Glyph(tui.ActiveTheme.Pieces))
+		}
+	} else {
+		for _, v := range gs.Board.CapturedPieces {
+			if v.Color == piece.WHITE {
+				fmt.Fprintf(&bCapSb, "%c", v.Glyph(tui.ActiveTheme.Pieces))
+			} else {
+				fmt.Fprintf(&wCapSb, "%c", v.Glyph(tui.ActiveTheme.Pieces))
+			}
+		}
+	}
+
+	wArea := r.Layout.BottomCaptures
+	bArea := r.Layout.TopCaptures
+	if boardRotated {
+		wArea, bArea = bArea, wArea
+	}
+	tui.DrawText(wCapSb.String(), wArea, tui.WHITE, tui.BLACK)
+	tui.DrawText(bCapSb.String(), bArea, tui.WHITE, tui.BLACK)
+}
+
+// Pieces in the player's pocket, the most valuable first
+func pocket(b board.Board, color piece.Color) []piece.Piece {
+	var pieces []piece.Piece
+	for _, t := range []piece.Type{piece.QUEEN, piece.ROOK, piece.BISHOP, piece.KNIGHT, piece.PAWN} {
+		for i := 0; i < b.Pockets[color][t]; i++ {
+			pieces = append(pieces, piece.Piece{Type: t, Color: color})
+		}
+	}
+	return pieces
+}
+
+func (r *ANSI) drawMoveHistory(gs *gamestate.GameState) {
+	if r.Layout.Compact {
+		r.drawMoveHistoryLine(gs)
+		return
+	}
+
+	// A row holds white's move and black's reply. Games set up with black to
+	// move start with a row of only the reply.
+	var rows []string
+	for i := range gs.MoveHistory {
+		num, black := gs.MoveNumber(i)
+		switch {
+		case !black:
+			rows = append(rows, fmt.Sprintf("%d. %-8s ", num, gs.MoveSAN(i)))
+		case i == 0:
+			rows = append(rows, fmt.Sprintf("%d. %-8s %s", num, "...", gs.MoveSAN(i)))
+		default:
+			rows[len(rows)-1] += gs.MoveSAN(i)
+		}
+	}
+	if numRows := max(r.Layout.History.Height, 0); len(rows) > numRows {
+		rows = rows[len(rows)-numRows:]
+	}
+
+	var sb strings.Builder
+	for _, row := range rows {
+		sb.WriteString(row + "\n")
+	}
+	tui.DrawText(sb.String(), r.Layout.History, tui.WHITE, tui.BLACK)
+}
+
+// The compact layout only has room for a single line with the latest moves
+func (r *ANSI) drawMoveHistoryLine(gs *gamestate.GameState) {
+	area := r.Layout.History
+	line := ""
+	for i := len(gs.MoveHistory) - 1; i >= 0; i-- {
+		entry := gs.MoveSAN(i)
+		if num, black := gs.MoveNumber(i); !black {
+			entry = fmt.Sprintf("%d. %s", num, entry)
+		} else if i == 0 {
+			entry = fmt.Sprintf("%d... %s", num, entry)
+		}
+		if line != "" {
+			entry += " "
+		}
+		if len([]rune(entry+line)) > area.Width {
+			break
+		}
+		line = entry + line
+	}
+	tui.DrawText(line, area, tui.WHITE, tui.BLACK)
+}
@@ -0,0 +1,128 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/gamestate"
+	"github.com/Jesselli/tchess/piece"
+)
+
+// Plain writes the game as plain text without any escape sequences. Moves are
+// described in words and the board is written out whenever the shown position
+// changes, which suits screen readers and logs.
+type Plain struct {
+	out      io.Writer
+	shownFen string // Position that was written out last
+}
+
+func NewPlain(out io.Writer) *Plain {
+	return &Plain{out: out}
+}
+
+// Describes a move in words, e.g. "White knight from g1 to f3, check"
+func DescribeMove(mv board.Move, before board.Board, after board.Board) string {
+	mover := piece.Piece{Type: mv.Piece, Color: mv.DropColor}
+	if !mv.Drop {
+		mover = before.Pieces[mv.SrcSqNum()]
+	}
+	captured := before.Pieces[mv.TrgSqNum()]
+	color := "White"
+	if mover.Color == piece.BLACK {
+		color = "Black"
+	}
+
+	var sb strings.Builder
+	if mv.Drop {
+		fmt.Fprintf(&sb, "%s drops a %s on %c%c", color, strings.ToLower(piece.PieceNames[mv.Piece]), mv.TrgFile, mv.TrgRank)
+	} else if mv.IsShortCastle() {
+		fmt.Fprintf(&sb, "%s castles kingside", color)
+	} else if mv.IsLongCastle() {
+		fmt.Fprintf(&sb, "%s castles queenside", color)
+	} else {
+		name := strings.ToLower(piece.PieceNames[mover.Type])
+		fmt.Fprintf(&sb, "%s %s from %c%c to %c%c", color, name, mv.SrcFile, mv.SrcRank, mv.TrgFile, mv.TrgRank)
+		if captured != piece.EMPTYP {
+			fmt.Fprintf(&sb, ", takes %s", strings.ToLower(captured.Name()))
+		}
+	}
+
+	promoted := after.Pieces[mv.TrgSqNum()]
+	if mover.Type == piece.PAWN && promoted.Type != piece.PAWN {
+		fmt.Fprintf(&sb, ", promotes to %s", strings.ToLower(piece.PieceNames[promoted.Type]))
+	}
+
+	opponent := mover.Color.Opposite()
+	if after.IsInCheck(opponent) {
+		if len(after.AllValidMoves(opponent)) == 0 {
+			fmt.Fprint(&sb, ", checkmate")
+		} else {
+			fmt.Fprint(&sb, ", check")
+		}
+	}
+	return sb.String()
+}
+
+func (r *Plain) MoveMade(gs *gamestate.GameState, mv board.Move) {
+	before, after := gs.BoardsAroundMove(len(gs.MoveHistory) - 1)
+	fmt.Fprintln(r.out, DescribeMove(mv, before, after))
+}
+
+func (r *Plain) ClockTick(gs *gamestate.GameState) {
+}
+
+func (r *Plain) StatusChanged(gs *gamestate.GameState) {
+	if gs.Status != gamestate.STATUS_PLAYING {
+		fmt.Fprintln(r.out, gs.Status)
+	}
+}
+
+func (r *Plain) Message(gs *gamestate.GameState, msg string) {
+	fmt.Fprintln(r.out, msg)
+}
+
+func (r *Plain) Redraw(gs *gamestate.GameState) {
+	shown := gs.Shown()
+	fen := shown.ToFen()
+	if fen == r.shownFen {
+		return
+	}
+	r.shownFen = fen
+
+	shown.Board.DisplayASCII(r.out, gs.IsBoardRotated())
+	toMove := "White to move."
+	if shown.ActiveColor == piece.BLACK {
+		toMove = "Black to move."
+	}
+	clocks := ""
+	if !gs.Clock.Control.Untimed() {
+		clocks = fmt.Sprintf(" White %s, Black %s", clockText(gs, piece.WHITE), clockText(gs, piece.BLACK))
+	}
+
+	if shown.Board.Rules().Drops() {
+		fmt.Fprintf(r.out, "In hand: White %s, Black %s\n", pocketText(shown.Board, piece.WHITE), pocketText(shown.Board, piece.BLACK))
+	}
+	if gs.Reviewing {
+		fmt.Fprintln(r.out, gs.ReviewMessage())
+	} else if gs.Status == gamestate.STATUS_PLAYING {
+		fmt.Fprintf(r.out, "%s%s\n", toMove, clocks)
+	}
+}
+
+// Lists the pieces in the player's pocket by their English letters, e.g. "N P P"
+func pocketText(b board.Board, color piece.Color) string {
+	var letters []string
+	for _, p := range pocket(b, color) {
+		letters = append(letters, string(piece.ToFenChar[piece.Piece{Type: p.Type, Color: piece.WHITE}]))
+	}
+	if len(letters) == 0 {
+		return "none"
+	}
+	return strings.Join(letters, " ")
+}
+
+func (r *Plain) Prompt(gs *gamestate.GameState, input string) {
+	fmt.Fprintf(r.out, "> %s", input)
+}
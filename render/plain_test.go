@@ -0,0 +1,30 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/Jesselli/tchess/gamestate"
+)
+
+func TestDescribeMove(t *testing.T) {
+	gs := gamestate.CreateDefault()
+	for _, mv := range []string{"e4", "f5", "exf5", "g5", "Qh5"} {
+		if err := gs.ParseAndExecuteAlgebraicNotation(mv); err != nil {
+			t.Fatalf("Could not play %s: %s", mv, err)
+		}
+	}
+
+	expected := []string{
+		"White pawn from e2 to e4",
+		"Black pawn from f7 to f5",
+		"White pawn from e4 to f5, takes black pawn",
+		"Black pawn from g7 to g5",
+		"White queen from d1 to h5, checkmate",
+	}
+	for i, want := range expected {
+		before, after := gs.BoardsAroundMove(i)
+		if actual := DescribeMove(gs.MoveHistory[i], before, after); actual != want {
+			t.Fatalf("Expected: %s, Actual: %s", want, actual)
+		}
+	}
+}
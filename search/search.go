@@ -0,0 +1,255 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/gamestate"
+	"github.com/Jesselli/tchess/piece"
+)
+
+const (
+	DEFAULT_MOVETIME = time.Second
+	MAX_DEPTH        = 32
+)
+
+// Scores are in centipawns from the point of view of the side to move
+const (
+	SCORE_MATE     = 100000
+	SCORE_INFINITE = 2 * SCORE_MATE
+)
+
+var pieceValues = map[piece.Type]int{
+	piece.PAWN:   100,
+	piece.KNIGHT: 320,
+	piece.BISHOP: 330,
+	piece.ROOK:   500,
+	piece.QUEEN:  900,
+}
+
+// Engine is a small alpha-beta search over material. It is no match for a real
+// UCI engine, but needs nothing to be installed.
+type Engine struct {
+	MoveTime time.Duration // Time to think about each move
+	Depth    int           // Plies to search at most, 0 searches until MoveTime is up
+}
+
+func New() *Engine {
+	return &Engine{MoveTime: DEFAULT_MOVETIME}
+}
+
+// Implements gamestate.Engine. Deepens the search one ply at a time and plays
+// the best move of the deepest search that finished in time.
+func (e *Engine) BestMove(ctx context.Context, fen string) (string, error) {
+	best, _, err := e.think(ctx, fen)
+	if err != nil {
+		return "", err
+	}
+	return best.ToLAN(), nil
+}
+
+// Implements gamestate.DrawJudge. A draw is accepted unless the search finds
+// the side to move better off.
+func (e *Engine) AcceptDraw(ctx context.Context, fen string) (bool, error) {
+	_, score, err := e.think(ctx, fen)
+	if err != nil {
+		return false, err
+	}
+	return score <= 0, nil
+}
+
+// Returns the best move and its score
+func (e *Engine) think(ctx context.Context, fen string) (board.Move, int, error) {
+	gs := gamestate.CreateDefault()
+	err := gs.LoadFen(fen)
+	if err != nil {
+		return board.Move{}, 0, err
+	}
+
+	moves := gs.Board.AllValidMoves(gs.ActiveColor)
+	if len(moves) == 0 {
+		return board.Move{}, 0, fmt.Errorf("There are no legal moves")
+	}
+	orderMoves(gs.Board, moves)
+
+	if e.MoveTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.MoveTime)
+		defer cancel()
+	}
+	maxDepth := e.Depth
+	if maxDepth <= 0 || maxDepth > MAX_DEPTH {
+		maxDepth = MAX_DEPTH
+	}
+
+	s := searcher{ctx: ctx}
+	best := moves[0]
+	bestScore := 0
+	for depth := 1; depth <= maxDepth; depth++ {
+		mv, score, ok := s.root(gs.Board, gs.ActiveColor, moves, depth)
+		if !ok {
+			break
+		}
+		best, bestScore = mv, score
+		if score >= SCORE_MATE-MAX_DEPTH || score <= -SCORE_MATE+MAX_DEPTH {
+			// Searching deeper will not find a quicker mate
+			break
+		}
+	}
+
+	if err := ctx.Err(); err != nil && err != context.DeadlineExceeded {
+		return board.Move{}, 0, err
+	}
+	return best, bestScore, nil
+}
+
+type searcher struct {
+	ctx context.Context
+}
+
+// Returns true once the search has to give up. Checking the context is cheap
+// next to generating the moves of a node.
+func (s *searcher) stopped() bool {
+	return s.ctx.Err() != nil
+}
+
+// Searches every root move. Returns false if the search did not finish.
+func (s *searcher) root(b board.Board, color piece.Color, moves []board.Move, depth int) (board.Move, int, bool) {
+	alpha := -SCORE_INFINITE
+	best := moves[0]
+	bestIdx := 0
+	for i, mv := range moves {
+		score := -s.negamax(play(b, mv, color), color.Opposite(), depth-1, 1, -SCORE_INFINITE, -alpha)
+		if s.ctx.Err() != nil {
+			return best, alpha, false
+		}
+		if score > alpha {
+			alpha = score
+			best = mv
+			bestIdx = i
+		}
+	}
+	// The next iteration looks at the best move first
+	copy(moves[1:bestIdx+1], moves[:bestIdx])
+	moves[0] = best
+	return best, alpha, true
+}
+
+func (s *searcher) negamax(b board.Board, color piece.Color, depth, ply, alpha, beta int) int {
+	if s.stopped() {
+		return 0
+	}
+
+	moves := b.AllValidMoves(color)
+	if len(moves) == 0 {
+		if b.IsInCheck(color) {
+			// Prefer the quickest mate
+			return -SCORE_MATE + ply
+		}
+		return 0
+	}
+	if depth <= 0 {
+		return s.quiesce(b, color, moves, alpha, beta)
+	}
+
+	orderMoves(b, moves)
+	for _, mv := range moves {
+		score := -s.negamax(play(b, mv, color), color.Opposite(), depth-1, ply+1, -beta, -alpha)
+		if score >= beta {
+			return beta
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// Follows captures until the position is quiet, so that a piece left hanging
+// at the end of the search is not counted
+func (s *searcher) quiesce(b board.Board, color piece.Color, moves []board.Move, alpha, beta int) int {
+	standPat := evaluate(b, color)
+	if standPat >= beta {
+		return beta
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	for _, mv := range moves {
+		if !mv.Capture || s.stopped() {
+			continue
+		}
+		next := play(b, mv, color)
+		replies := next.AllValidMoves(color.Opposite())
+		var score int
+		if len(replies) == 0 && next.IsInCheck(color.Opposite()) {
+			score = SCORE_MATE
+		} else if len(replies) == 0 {
+			score = 0
+		} else {
+			score = -s.quiesce(next, color.Opposite(), replies, -beta, -alpha)
+		}
+		if score >= beta {
+			return beta
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// Material balance, with a little extra for advanced pawns and central pieces
+func evaluate(b board.Board, color piece.Color) int {
+	score := 0
+	for sq, p := range b.Pieces {
+		if p.Type == piece.NONE || p.Type == piece.KING {
+			continue
+		}
+		value := pieceValues[p.Type]
+		file, rank := sq%8, 7-sq/8
+		if p.Color == piece.BLACK {
+			rank = 7 - rank
+		}
+		switch p.Type {
+		case piece.PAWN:
+			value += 5 * (rank - 1)
+		case piece.KNIGHT, piece.BISHOP:
+			value += 10 - 3*(board.Abs(2*file-7)+board.Abs(2*rank-7))/2
+		}
+
+		if p.Color == color {
+			score += value
+		} else {
+			score -= value
+		}
+	}
+	return score
+}
+
+// Returns the board after the move. The captured pieces are not tracked.
+func play(b board.Board, mv board.Move, color piece.Color) board.Board {
+	b.CapturedPieces = nil
+	b.UpdateBoardWithMove(mv)
+	b.UpdateCastleRightsWithMove(mv, color)
+	return b
+}
+
+// Puts the captures of the most valuable pieces first, so that alpha-beta can
+// cut off more of the other moves
+func orderMoves(b board.Board, moves []board.Move) {
+	sort.SliceStable(moves, func(i, j int) bool {
+		return captureValue(b, moves[i]) > captureValue(b, moves[j])
+	})
+}
+
+func captureValue(b board.Board, mv board.Move) int {
+	if !mv.Capture {
+		return 0
+	}
+	return pieceValues[b.Pieces[mv.TrgSqNum()].Type] - pieceValues[mv.Piece]/10
+}
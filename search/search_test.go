@@ -0,0 +1,61 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBestMove(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want string
+	}{
+		{"back rank mate", "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", "a1a8"},
+		{"hanging queen", "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1", "d2d5"},
+		{"mate for black", "r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1", "a8a1"},
+		{"promotion", "8/4P1k1/8/8/8/8/8/4K3 w - - 0 1", "e7e8q"},
+	}
+
+	for _, tt := range tests {
+		e := &Engine{MoveTime: 5 * time.Second, Depth: 2}
+		got, err := e.BestMove(context.Background(), tt.fen)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: played %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBestMoveTimeLimit(t *testing.T) {
+	e := &Engine{MoveTime: 50 * time.Millisecond}
+	start := time.Now()
+	mv, err := e.BestMove(context.Background(), "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil || mv == "" {
+		t.Fatalf("BestMove returned %q, %v", mv, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Search took %v with a 50ms limit", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = e.BestMove(ctx, "4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	if err != context.Canceled {
+		t.Errorf("Cancelled search returned %v", err)
+	}
+}
+
+func TestAcceptDraw(t *testing.T) {
+	e := &Engine{MoveTime: 5 * time.Second, Depth: 2}
+	ahead, err := e.AcceptDraw(context.Background(), "4k3/8/8/8/8/8/8/Q3K3 w - - 0 1")
+	if err != nil || ahead {
+		t.Errorf("A queen up the engine accepted a draw (%v)", err)
+	}
+	behind, err := e.AcceptDraw(context.Background(), "4k3/8/8/8/8/8/8/q3K3 w - - 0 1")
+	if err != nil || !behind {
+		t.Errorf("A queen down the engine declined a draw (%v)", err)
+	}
+}
@@ -0,0 +1,179 @@
+package suite
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Jesselli/tchess/gamestate"
+)
+
+// Result of one EPD record of a test suite
+type Result struct {
+	Line    int // 1-based line of the record in the suite
+	ID      string
+	Comment string   // The c0 operation, if any
+	Best    []string // Moves of the bm operation as written in the suite
+	Avoid   []string // Moves of the am operation
+	Played  string   // Move of the engine in long algebraic notation
+	Solved  bool
+	Err     error // Set if the record could not be used or the engine failed
+}
+
+// Totals of a test suite
+type Summary struct {
+	Solved int
+	Failed int
+	Errors int
+}
+
+func (s Summary) String() string {
+	tried := s.Solved + s.Failed
+	percent := 0
+	if tried > 0 {
+		percent = 100 * s.Solved / tried
+	}
+	msg := fmt.Sprintf("Solved %d of %d positions (%d%%)", s.Solved, tried, percent)
+	if s.Errors > 0 {
+		msg += fmt.Sprintf(", %d could not be tried", s.Errors)
+	}
+	return msg
+}
+
+// Asks the engine for its move in every position of the suite, one EPD record
+// per line, and checks it against the bm and am operations. Each result is
+// written to out as soon as it is known. Blank lines and lines starting with '#'
+// are skipped.
+func Run(ctx context.Context, suite io.Reader, engine gamestate.Engine, out io.Writer) (Summary, error) {
+	var summary Summary
+	scanner := bufio.NewScanner(suite)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		res := Solve(ctx, line, engine)
+		res.Line = lineNum
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+
+		switch {
+		case res.Err != nil:
+			summary.Errors++
+		case res.Solved:
+			summary.Solved++
+		default:
+			summary.Failed++
+		}
+		fmt.Fprintln(out, res)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("Could not read the test suite. %w", err)
+	}
+	return summary, nil
+}
+
+// Asks the engine for its move in the position of a single EPD record
+func Solve(ctx context.Context, line string, engine gamestate.Engine) Result {
+	var res Result
+	gs := gamestate.CreateDefault()
+	// A bm of e8 means e8=Q
+	gs.AutoQueen = true
+	epd, err := gs.SetupEPD(line)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	if id, ok := epd.Get("id"); ok {
+		res.ID = strings.Join(id, " ")
+	}
+	if comment, ok := epd.Get("c0"); ok {
+		res.Comment = strings.Join(comment, " ")
+	}
+	res.Best, _ = epd.Get("bm")
+	res.Avoid, _ = epd.Get("am")
+	if len(res.Best) == 0 && len(res.Avoid) == 0 {
+		res.Err = fmt.Errorf("There is no bm or am operation to check the move against")
+		return res
+	}
+
+	// The moves are compared in long algebraic notation, which is what the
+	// engine replies with
+	best, err := toLAN(gs, res.Best)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	avoid, err := toLAN(gs, res.Avoid)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Played, err = engine.BestMove(ctx, gs.ToFen())
+	if err != nil {
+		res.Err = fmt.Errorf("The engine failed. %w", err)
+		return res
+	}
+	res.Played = strings.ToLower(res.Played)
+	res.Solved = (len(best) == 0 || contains(best, res.Played)) && !contains(avoid, res.Played)
+	return res
+}
+
+func (r Result) String() string {
+	id := r.ID
+	if id == "" {
+		id = fmt.Sprintf("line %d", r.Line)
+	}
+	if r.Err != nil {
+		return fmt.Sprintf("%-16s error   %s", id, r.Err)
+	}
+
+	verdict := "failed"
+	if r.Solved {
+		verdict = "solved"
+	}
+	var expected []string
+	if len(r.Best) > 0 {
+		expected = append(expected, "bm "+strings.Join(r.Best, " "))
+	}
+	if len(r.Avoid) > 0 {
+		expected = append(expected, "am "+strings.Join(r.Avoid, " "))
+	}
+	s := fmt.Sprintf("%-16s %-7s %s, played %s", id, verdict, strings.Join(expected, "; "), r.Played)
+	if r.Comment != "" {
+		s += fmt.Sprintf(" (%s)", r.Comment)
+	}
+	return s
+}
+
+// Turns moves in standard algebraic notation into the long algebraic notation
+// of UCI, e.g. Nxe5 into f3e5 and e8=Q into e7e8q
+func toLAN(gs *gamestate.GameState, moves []string) ([]string, error) {
+	lans := make([]string, len(moves))
+	for i, san := range moves {
+		mv, err := gs.FindMove(san)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a legal move. %w", san, err)
+		}
+		lans[i] = mv.ToLAN()
+	}
+	return lans, nil
+}
+
+func contains(moves []string, mv string) bool {
+	for _, m := range moves {
+		if m == mv {
+			return true
+		}
+	}
+	return false
+}
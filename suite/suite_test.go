@@ -0,0 +1,57 @@
+package suite
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Plays the same move in every position
+type fixedEngine string
+
+func (e fixedEngine) BestMove(ctx context.Context, fen string) (string, error) {
+	return string(e), nil
+}
+
+func TestRun(t *testing.T) {
+	suite := `# Positions after 1. e4
+rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - bm e5; id "best";
+rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - bm c5 Nf6; id "other";
+rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - am e5; c0 "avoided";
+
+rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - id "nothing to check";
+rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - bm e4;
+`
+	var out strings.Builder
+	summary, err := Run(context.Background(), strings.NewReader(suite), fixedEngine("e7e5"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != (Summary{Solved: 1, Failed: 2, Errors: 2}) {
+		t.Errorf("Summary is %+v\n%s", summary, out.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	wantPrefixes := []string{"best             solved", "other            failed", "line 4           failed", "nothing to check error", "line 7           error"}
+	if len(lines) != len(wantPrefixes) {
+		t.Fatalf("Wrote %d results\n%s", len(lines), out.String())
+	}
+	for i, want := range wantPrefixes {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("Result %d is %q, want it to start with %q", i+1, lines[i], want)
+		}
+	}
+}
+
+func TestSolvePromotionAndSuffixes(t *testing.T) {
+	line := `8/4P1k1/8/8/8/8/8/4K3 w - - bm e8=Q+; id "promote";`
+	res := Solve(context.Background(), line, fixedEngine("e7e8q"))
+	if res.Err != nil || !res.Solved {
+		t.Errorf("Solve returned %+v", res)
+	}
+
+	res = Solve(context.Background(), line, fixedEngine("e7e8n"))
+	if res.Err != nil || res.Solved {
+		t.Errorf("Underpromotion counted as solved: %+v", res)
+	}
+}
@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type ColorMode int
+
+const (
+	COLOR_MODE_TRUECOLOR ColorMode = 0
+	COLOR_MODE_256       ColorMode = 1
+	COLOR_MODE_16        ColorMode = 2
+)
+
+var ColorModeNames = map[string]ColorMode{
+	"truecolor": COLOR_MODE_TRUECOLOR,
+	"256":       COLOR_MODE_256,
+	"16":        COLOR_MODE_16,
+}
+
+// Colors are always specified as RGB and converted to the closest color the
+// terminal can show when it has no truecolor support.
+var ActiveColorMode = COLOR_MODE_TRUECOLOR
+
+// Guesses the color support of the terminal from the environment
+func DetectColorMode() ColorMode {
+	colorTerm := os.Getenv("COLORTERM")
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return COLOR_MODE_TRUECOLOR
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return COLOR_MODE_256
+	}
+	return COLOR_MODE_16
+}
+
+// The basic ANSI colors, approximated as xterm shows them
+var ansi16 = [16]RGB{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+func colorDistance(a, b RGB) int {
+	dr := a[0] - b[0]
+	dg := a[1] - b[1]
+	db := a[2] - b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+// Index into the 6x6x6 color cube of the 256 color palette
+func to256(c RGB) int {
+	levels := [6]int{0, 95, 135, 175, 215, 255}
+	idx := 16
+	for i, mult := range [3]int{36, 6, 1} {
+		best := 0
+		for l, v := range levels {
+			if abs(v-c[i]) < abs(levels[best]-c[i]) {
+				best = l
+			}
+		}
+		idx += best * mult
+	}
+	return idx
+}
+
+func to16(c RGB) int {
+	return nearest16(c, nil)
+}
+
+// Index of the closest basic color that is not used yet
+func nearest16(c RGB, used map[int]bool) int {
+	best := -1
+	for i, v := range ansi16 {
+		if used[i] {
+			continue
+		}
+		if best < 0 || colorDistance(v, c) < colorDistance(ansi16[best], c) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// Escape sequence that sets the foreground color
+func FgCode(c RGB) string {
+	switch ActiveColorMode {
+	case COLOR_MODE_256:
+		return fmt.Sprintf("\033[38;5;%dm", to256(c))
+	case COLOR_MODE_16:
+		idx := to16(c)
+		if idx >= 8 {
+			return fmt.Sprintf("\033[%dm", 90+idx-8)
+		}
+		return fmt.Sprintf("\033[%dm", 30+idx)
+	}
+	return fmt.Sprintf("\033[38;2;%d;%d;%dm", c[0], c[1], c[2])
+}
+
+// Escape sequence that sets the background color
+func BgCode(c RGB) string {
+	switch ActiveColorMode {
+	case COLOR_MODE_256:
+		return fmt.Sprintf("\033[48;5;%dm", to256(c))
+	case COLOR_MODE_16:
+		idx := to16(c)
+		if idx >= 8 {
+			return fmt.Sprintf("\033[%dm", 100+idx-8)
+		}
+		return fmt.Sprintf("\033[%dm", 40+idx)
+	}
+	return fmt.Sprintf("\033[48;2;%d;%d;%dm", c[0], c[1], c[2])
+}
+
+// Parses colors written as #rrggbb
+func ParseHexColor(hex string) (RGB, error) {
+	var c RGB
+	_, err := fmt.Sscanf(hex, "#%02x%02x%02x", &c[0], &c[1], &c[2])
+	if err != nil || len(hex) != 7 {
+		return c, fmt.Errorf("Invalid color '%s'. Colors are written as #rrggbb", hex)
+	}
+	return c, nil
+}
+
+func (c *RGB) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseHexColor(strings.Trim(string(data), `"`))
+	if err == nil {
+		*c = parsed
+	}
+	return err
+}
+
+func (c RGB) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"#%02x%02x%02x"`, c[0], c[1], c[2])), nil
+}
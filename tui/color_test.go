@@ -0,0 +1,47 @@
+package tui
+
+import "testing"
+
+func TestColorFallback(t *testing.T) {
+	defer func(mode ColorMode) { ActiveColorMode = mode }(ActiveColorMode)
+
+	theme := Themes["blue"]
+	ActiveColorMode = COLOR_MODE_256
+	cases := map[RGB]string{
+		theme.LightSquare: "\033[48;5;74m",
+		theme.DarkSquare:  "\033[48;5;24m",
+		theme.LastMove:    "\033[48;5;81m",
+		theme.BlackPiece:  "\033[48;5;16m",
+	}
+	for c, expected := range cases {
+		if actual := BgCode(c); actual != expected {
+			t.Fatalf("Expected: %q, Actual: %q", expected, actual)
+		}
+	}
+
+	ActiveColorMode = COLOR_MODE_16
+	if actual := FgCode(RGB{250, 250, 250}); actual != "\033[97m" {
+		t.Fatalf("Expected bright white, Actual: %q", actual)
+	}
+}
+
+func TestHighlightsIn16Colors(t *testing.T) {
+	for name, theme := range Themes {
+		theme = theme.For16Colors()
+		seen := map[int]string{to16(theme.LightSquare): "light square", to16(theme.DarkSquare): "dark square"}
+		highlights := map[string]RGB{
+			"last move": theme.LastMove,
+			"check":     theme.Check,
+			"target":    theme.Target,
+			"selected":  theme.Selected,
+			"cursor":    theme.Cursor,
+		}
+		for highlight, c := range highlights {
+			idx := to16(c)
+			if other, ok := seen[idx]; ok {
+				t.Errorf("%s: %s and %s are both color %d", name, highlight, other, idx)
+			}
+			seen[idx] = highlight
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Key int
+
+const (
+	KEY_RUNE      Key = 0
+	KEY_UP        Key = 1
+	KEY_DOWN      Key = 2
+	KEY_LEFT      Key = 3
+	KEY_RIGHT     Key = 4
+	KEY_ENTER     Key = 5
+	KEY_ESC       Key = 6
+	KEY_BACKSPACE Key = 7
+	KEY_CTRL_C    Key = 8
+	KEY_UNKNOWN   Key = 9
+	KEY_MOUSE     Key = 10
+)
+
+const (
+	MOUSE_LEFT   = 0
+	MOUSE_MIDDLE = 1
+	MOUSE_RIGHT  = 2
+
+	mouseMotionFlag = 32
+	mouseWheelFlag  = 64
+)
+
+type MouseEvent struct {
+	Button  int
+	Row     int // 1-based, like MoveCursorTo
+	Col     int
+	Press   bool // false when the button was released
+	Drag    bool // Motion while a button is held down
+	IsWheel bool
+}
+
+type KeyEvent struct {
+	Key   Key
+	Rune  rune       // Only set for KEY_RUNE
+	Mouse MouseEvent // Only set for KEY_MOUSE
+}
+
+// KeyReader decodes the bytes coming from a terminal in raw mode into key
+// presses, including the escape sequences sent for the arrow keys.
+type KeyReader struct {
+	in *bufio.Reader
+}
+
+func NewKeyReader(in io.Reader) *KeyReader {
+	return &KeyReader{in: bufio.NewReader(in)}
+}
+
+func (kr *KeyReader) ReadKey() (KeyEvent, error) {
+	r, _, err := kr.in.ReadRune()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return KeyEvent{Key: KEY_ENTER}, nil
+	case 0x7f, '\b':
+		return KeyEvent{Key: KEY_BACKSPACE}, nil
+	case 0x03:
+		return KeyEvent{Key: KEY_CTRL_C}, nil
+	case 0x1b:
+		// A lone escape is the Esc key. Escape sequences arrive in a single
+		// read, so anything already buffered belongs to the sequence.
+		if kr.in.Buffered() == 0 {
+			return KeyEvent{Key: KEY_ESC}, nil
+		}
+		return kr.readEscapeSequence()
+	}
+
+	if r < ' ' {
+		return KeyEvent{Key: KEY_UNKNOWN}, nil
+	}
+	return KeyEvent{Key: KEY_RUNE, Rune: r}, nil
+}
+
+func (kr *KeyReader) readEscapeSequence() (KeyEvent, error) {
+	intro, err := kr.in.ReadByte()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+	if intro != '[' && intro != 'O' {
+		return KeyEvent{Key: KEY_UNKNOWN}, nil
+	}
+
+	// Collect any parameters until the final byte of the sequence
+	var params strings.Builder
+	var final byte
+	for {
+		final, err = kr.in.ReadByte()
+		if err != nil {
+			return KeyEvent{}, err
+		}
+		if final >= 0x40 && final <= 0x7e {
+			break
+		}
+		params.WriteByte(final)
+	}
+
+	if strings.HasPrefix(params.String(), "<") && (final == 'M' || final == 'm') {
+		return parseSGRMouse(params.String()[1:], final == 'M')
+	}
+
+	switch final {
+	case 'A':
+		return KeyEvent{Key: KEY_UP}, nil
+	case 'B':
+		return KeyEvent{Key: KEY_DOWN}, nil
+	case 'C':
+		return KeyEvent{Key: KEY_RIGHT}, nil
+	case 'D':
+		return KeyEvent{Key: KEY_LEFT}, nil
+	}
+	return KeyEvent{Key: KEY_UNKNOWN}, nil
+}
+
+// Parses the body of an SGR mouse report: ESC [ < button ; col ; row M|m
+func parseSGRMouse(params string, press bool) (KeyEvent, error) {
+	fields := strings.Split(params, ";")
+	if len(fields) != 3 {
+		return KeyEvent{Key: KEY_UNKNOWN}, nil
+	}
+
+	var nums [3]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return KeyEvent{Key: KEY_UNKNOWN}, nil
+		}
+		nums[i] = n
+	}
+
+	ev := MouseEvent{}
+	ev.Button = nums[0] & 0b11
+	ev.Drag = nums[0]&mouseMotionFlag != 0
+	ev.IsWheel = nums[0]&mouseWheelFlag != 0
+	ev.Col = nums[1]
+	ev.Row = nums[2]
+	ev.Press = press
+	return KeyEvent{Key: KEY_MOUSE, Mouse: ev}, nil
+}
@@ -0,0 +1,45 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadKeys(t *testing.T) {
+	kr := NewKeyReader(strings.NewReader("e4\x1b[A\x1b[D\x7f\r"))
+	expected := []KeyEvent{
+		{Key: KEY_RUNE, Rune: 'e'},
+		{Key: KEY_RUNE, Rune: '4'},
+		{Key: KEY_UP},
+		{Key: KEY_LEFT},
+		{Key: KEY_BACKSPACE},
+		{Key: KEY_ENTER},
+	}
+	for _, want := range expected {
+		got, err := kr.ReadKey()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if got != want {
+			t.Fatalf("Expected: %+v, Actual: %+v", want, got)
+		}
+	}
+}
+
+func TestReadMouse(t *testing.T) {
+	kr := NewKeyReader(strings.NewReader("\x1b[<0;6;3M\x1b[<32;8;4M\x1b[<0;8;4m"))
+	expected := []MouseEvent{
+		{Button: MOUSE_LEFT, Row: 3, Col: 6, Press: true},
+		{Button: MOUSE_LEFT, Row: 4, Col: 8, Press: true, Drag: true},
+		{Button: MOUSE_LEFT, Row: 4, Col: 8, Press: false},
+	}
+	for _, want := range expected {
+		got, err := kr.ReadKey()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if got.Key != KEY_MOUSE || got.Mouse != want {
+			t.Fatalf("Expected: %+v, Actual: %+v", want, got)
+		}
+	}
+}
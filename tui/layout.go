@@ -0,0 +1,113 @@
+package tui
+
+// Rect is an area of the terminal. Row and Col are 1-based like MoveCursorTo.
+type Rect struct {
+	Row    int
+	Col    int
+	Width  int
+	Height int
+}
+
+// Layout positions every panel of the game screen for a given terminal size.
+// The board grows with the terminal, and narrow terminals get a compact layout
+// that stacks the panels below the board instead of beside it.
+type Layout struct {
+	Width          int
+	Height         int
+	Compact        bool
+	SquareWidth    int // Columns per board square
+	SquareHeight   int // Rows per board square
+	Board          Rect
+	TopClock       Rect
+	BottomClock    Rect
+	TopCaptures    Rect
+	BottomCaptures Rect
+	History        Rect
+	Engine         Rect
+	Prompt         Rect // Message line followed by the input line
+}
+
+const (
+	DefaultWidth  = 80
+	DefaultHeight = 24
+
+	maxBoardScale   = 3
+	rankLabelWidth  = 3
+	sidePanelWidth  = 16 // Clocks and captures
+	historyWidth    = 20
+	promptHeight    = 2
+	clockTextWidth  = 7
+	panelGap        = 2
+	compactRowsUsed = 4 // Clock, history, engine and the board's file labels
+)
+
+func boardRect(scale int) Rect {
+	return Rect{
+		Row:    2,
+		Col:    1,
+		Width:  rankLabelWidth + 8*2*scale,
+		Height: 8*scale + 1, // The last row holds the file labels
+	}
+}
+
+func ComputeLayout(width, height int) Layout {
+	l := fitLayout(width, height)
+	// Terminals too small for even the compact layout leave some panels with
+	// no room at all
+	for _, r := range []*Rect{&l.Board, &l.TopClock, &l.BottomClock, &l.TopCaptures, &l.BottomCaptures, &l.History, &l.Engine, &l.Prompt} {
+		r.Width = max(r.Width, 0)
+		r.Height = max(r.Height, 0)
+	}
+	return l
+}
+
+func fitLayout(width, height int) Layout {
+	for scale := maxBoardScale; scale >= 1; scale-- {
+		b := boardRect(scale)
+		fitsWide := b.Col+b.Width+panelGap+sidePanelWidth+historyWidth <= width &&
+			b.Row+b.Height+promptHeight <= height
+		if fitsWide {
+			return wideLayout(width, height, scale)
+		}
+	}
+
+	for scale := maxBoardScale; scale > 1; scale-- {
+		b := boardRect(scale)
+		if b.Width <= width && b.Row+b.Height+compactRowsUsed+promptHeight <= height {
+			return compactLayout(width, height, scale)
+		}
+	}
+	return compactLayout(width, height, 1)
+}
+
+func wideLayout(width, height, scale int) Layout {
+	l := Layout{Width: width, Height: height, SquareWidth: 2 * scale, SquareHeight: scale}
+	l.Board = boardRect(scale)
+
+	sideCol := l.Board.Col + l.Board.Width + panelGap
+	ranksHeight := 8 * scale
+	l.TopClock = Rect{Row: l.Board.Row, Col: sideCol, Width: clockTextWidth + 4, Height: 3}
+	l.BottomClock = Rect{Row: l.Board.Row + ranksHeight - 3, Col: sideCol, Width: clockTextWidth + 4, Height: 3}
+	l.TopCaptures = Rect{Row: l.TopClock.Row + 3, Col: sideCol, Width: sidePanelWidth, Height: 1}
+	l.BottomCaptures = Rect{Row: l.BottomClock.Row - 1, Col: sideCol, Width: sidePanelWidth, Height: 1}
+	l.History = Rect{Row: l.Board.Row, Col: sideCol + sidePanelWidth, Width: width - sideCol - sidePanelWidth, Height: ranksHeight}
+	l.Engine = Rect{Row: l.Board.Row + ranksHeight, Col: sideCol, Width: width - sideCol, Height: 1}
+	l.Prompt = Rect{Row: l.Board.Row + l.Board.Height, Col: 1, Width: width, Height: promptHeight}
+	return l
+}
+
+func compactLayout(width, height, scale int) Layout {
+	l := Layout{Width: width, Height: height, Compact: true, SquareWidth: 2 * scale, SquareHeight: scale}
+	l.Board = boardRect(scale)
+
+	below := l.Board.Row + l.Board.Height
+	capturesCol := rankLabelWidth + 1 + clockTextWidth
+	l.TopClock = Rect{Row: 1, Col: rankLabelWidth + 1, Width: clockTextWidth, Height: 1}
+	l.TopCaptures = Rect{Row: 1, Col: capturesCol, Width: width - capturesCol, Height: 1}
+	l.BottomClock = Rect{Row: below, Col: rankLabelWidth + 1, Width: clockTextWidth, Height: 1}
+	l.BottomCaptures = Rect{Row: below, Col: capturesCol, Width: width - capturesCol, Height: 1}
+	l.History = Rect{Row: below + 1, Col: 1, Width: width, Height: 1}
+	l.Engine = Rect{Row: below + 2, Col: 1, Width: width, Height: 1}
+	l.Prompt = Rect{Row: below + 3, Col: 1, Width: width, Height: promptHeight}
+	return l
+}
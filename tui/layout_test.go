@@ -0,0 +1,40 @@
+package tui
+
+import "testing"
+
+func TestComputeLayout(t *testing.T) {
+	tests := []struct {
+		width, height int
+		compact       bool
+		squareWidth   int
+	}{
+		{DefaultWidth, DefaultHeight, false, 4},
+		{120, 40, false, 6},
+		{60, 40, false, 2},
+		{52, 40, true, 6},
+		{40, 24, true, 2},
+		{10, 5, true, 2},
+		{1, 1, true, 2},
+		{0, 0, true, 2},
+	}
+
+	for _, tt := range tests {
+		l := ComputeLayout(tt.width, tt.height)
+		if l.Compact != tt.compact || l.SquareWidth != tt.squareWidth {
+			t.Errorf("%dx%d: got compact %v with squares %d wide, want %v and %d", tt.width, tt.height, l.Compact, l.SquareWidth, tt.compact, tt.squareWidth)
+		}
+		rects := map[string]Rect{
+			"board": l.Board, "top clock": l.TopClock, "bottom clock": l.BottomClock,
+			"top captures": l.TopCaptures, "bottom captures": l.BottomCaptures,
+			"history": l.History, "engine": l.Engine, "prompt": l.Prompt,
+		}
+		for name, r := range rects {
+			if r.Width < 0 || r.Height < 0 {
+				t.Errorf("%dx%d: the %s is %dx%d", tt.width, tt.height, name, r.Width, r.Height)
+			}
+			if !tt.compact && r.Col+r.Width-1 > tt.width {
+				t.Errorf("%dx%d: the %s ends at column %d", tt.width, tt.height, name, r.Col+r.Width-1)
+			}
+		}
+	}
+}
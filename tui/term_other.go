@@ -0,0 +1,31 @@
+//go:build !linux && !darwin
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+type TermState struct{}
+
+var errUnsupported = errors.New("raw terminal mode is not supported on this platform")
+
+func IsTerminal(fd int) bool {
+	return false
+}
+
+func EnableRawMode(fd int) (*TermState, error) {
+	return nil, errUnsupported
+}
+
+func RestoreMode(fd int, state *TermState) error {
+	return errUnsupported
+}
+
+func TerminalSize(fd int) (width, height int, err error) {
+	return 0, 0, errUnsupported
+}
+
+func NotifyResize(c chan<- os.Signal) {
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Theme holds the colors used to draw the board, and the name of the glyph
+// set used for the pieces. Themes can be loaded from a JSON file where colors
+// are written as "#rrggbb"; fields that are left out keep the default value.
+type Theme struct {
+	LightSquare RGB    `json:"light_square"`
+	DarkSquare  RGB    `json:"dark_square"`
+	WhitePiece  RGB    `json:"white_piece"`
+	BlackPiece  RGB    `json:"black_piece"`
+	LastMove    RGB    `json:"last_move"`
+	Check       RGB    `json:"check"`
+	Target      RGB    `json:"target"`
+	Selected    RGB    `json:"selected"`
+	Cursor      RGB    `json:"cursor"`
+	Pieces      string `json:"pieces"`
+}
+
+var Themes = map[string]Theme{
+	"blue": {
+		LightSquare: RGB{95, 175, 215},
+		DarkSquare:  RGB{0, 95, 135},
+		WhitePiece:  RGB{255, 255, 255},
+		BlackPiece:  RGB{0, 0, 0},
+		LastMove:    RGB{95, 215, 255},
+		Check:       RGB{215, 0, 0},
+		Target:      RGB{135, 175, 95},
+		Selected:    RGB{215, 175, 95},
+		Cursor:      RGB{255, 215, 135},
+		Pieces:      "filled",
+	},
+	"green": {
+		LightSquare: RGB{238, 238, 210},
+		DarkSquare:  RGB{118, 150, 86},
+		WhitePiece:  RGB{255, 255, 255},
+		BlackPiece:  RGB{0, 0, 0},
+		LastMove:    RGB{246, 246, 105},
+		Check:       RGB{215, 0, 0},
+		Target:      RGB{100, 170, 230},
+		Selected:    RGB{186, 202, 68},
+		Cursor:      RGB{255, 175, 95},
+		Pieces:      "filled",
+	},
+	"brown": {
+		LightSquare: RGB{240, 217, 181},
+		DarkSquare:  RGB{181, 136, 99},
+		WhitePiece:  RGB{255, 255, 255},
+		BlackPiece:  RGB{0, 0, 0},
+		LastMove:    RGB{205, 210, 106},
+		Check:       RGB{215, 0, 0},
+		Target:      RGB{130, 151, 105},
+		Selected:    RGB{170, 162, 58},
+		Cursor:      RGB{255, 215, 135},
+		Pieces:      "filled",
+	},
+	"gray": {
+		LightSquare: RGB{178, 178, 178},
+		DarkSquare:  RGB{98, 98, 98},
+		WhitePiece:  RGB{255, 255, 255},
+		BlackPiece:  RGB{0, 0, 0},
+		LastMove:    RGB{135, 175, 215},
+		Check:       RGB{215, 0, 0},
+		Target:      RGB{135, 175, 95},
+		Selected:    RGB{215, 175, 95},
+		Cursor:      RGB{255, 215, 135},
+		Pieces:      "filled",
+	},
+}
+
+var ActiveTheme = Themes["blue"]
+
+// Returns the theme with every highlight moved to a basic color of its own.
+// With only 16 colors several highlights would otherwise fall back to the
+// color of a square or a piece and disappear.
+func (t Theme) For16Colors() Theme {
+	used := make(map[int]bool)
+	for _, c := range []RGB{t.LightSquare, t.DarkSquare, t.WhitePiece, t.BlackPiece} {
+		used[to16(c)] = true
+	}
+	for _, c := range []*RGB{&t.LastMove, &t.Check, &t.Target, &t.Selected, &t.Cursor} {
+		idx := nearest16(*c, used)
+		used[idx] = true
+		*c = ansi16[idx]
+	}
+	return t
+}
+
+// Returns the built-in theme with the given name, or loads the theme from the
+// file at that path.
+func LoadTheme(nameOrPath string) (Theme, error) {
+	if theme, ok := Themes[nameOrPath]; ok {
+		return theme, nil
+	}
+
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return Theme{}, fmt.Errorf("'%s' is neither a theme name nor a readable file. %w", nameOrPath, err)
+	}
+
+	theme := Themes["blue"]
+	err = json.Unmarshal(data, &theme)
+	if err != nil {
+		return Theme{}, fmt.Errorf("Could not parse theme file %s. %w", nameOrPath, err)
+	}
+	return theme, nil
+}
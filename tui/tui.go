@@ -8,6 +8,10 @@ import (
 
 type RGB [3]int
 
+// In plain mode none of the escape sequences that move the cursor or change
+// colors are written, so the output can be read by screen readers or logged.
+var PlainMode = false
+
 var (
 	WHITE = RGB{255, 255, 255}
 	BLACK = RGB{0, 0, 0}
@@ -16,6 +20,10 @@ var (
 )
 
 func DrawMsgBox(msg string, x, y int, fg RGB, bg RGB, border bool) {
+	if PlainMode {
+		return
+	}
+
 	lines := strings.Split(msg, "\n")
 	width := 0
 	for _, line := range lines {
@@ -66,35 +74,97 @@ func DrawMsgBox(msg string, x, y int, fg RGB, bg RGB, border bool) {
 	}
 }
 
+// Writes text into the area, one line per row. Lines are padded or cut to the
+// width of the area so that anything drawn there before is overwritten.
+func DrawText(msg string, area Rect, fg RGB, bg RGB) {
+	if PlainMode || area.Width <= 0 {
+		return
+	}
+
+	lines := strings.Split(msg, "\n")
+
+	SaveCursorPos()
+	defer RestoreCursorPos()
+
+	SetFgBg(fg, bg)
+	defer ResetStyle()
+
+	for i := 0; i < area.Height; i++ {
+		var line []rune
+		if i < len(lines) {
+			line = []rune(lines[i])
+		}
+		if len(line) > area.Width {
+			line = line[:area.Width]
+		}
+
+		MoveCursorTo(area.Row+i, area.Col)
+		fmt.Fprintf(os.Stdout, "%s%s", string(line), strings.Repeat(" ", area.Width-len(line)))
+	}
+}
+
 func ResetStyle() {
+	if PlainMode {
+		return
+	}
+
 	fmt.Fprintf(os.Stdout, "\033[0m")
 }
 
 func MoveCursorTo(row, col int) {
+	if PlainMode {
+		return
+	}
+
 	fmt.Fprintf(os.Stdout, "\033[%d;%dH", row, col)
 }
 
 func SaveCursorPos() {
+	if PlainMode {
+		return
+	}
+
 	fmt.Fprint(os.Stdout, "\033[s")
 }
 
 func RestoreCursorPos() {
+	if PlainMode {
+		return
+	}
+
 	fmt.Fprint(os.Stdout, "\033[u")
 }
 
+func ClearScreen() {
+	if PlainMode {
+		return
+	}
+
+	fmt.Fprint(os.Stdout, "\033[2J")
+}
+
 func EraseLine() {
+	if PlainMode {
+		return
+	}
+
 	fmt.Fprintf(os.Stdout, "\033[2K")
 }
 
 func SetFgBg(fg, bg RGB) {
-	fgStr := fmt.Sprintf("\033[38;2;%d;%d;%dm", fg[0], fg[1], fg[2])
-	fmt.Fprint(os.Stdout, fgStr)
+	if PlainMode {
+		return
+	}
 
-	bgStr := fmt.Sprintf("\033[48;2;%d;%d;%dm", bg[0], bg[1], bg[2])
-	fmt.Fprint(os.Stdout, bgStr)
+	fmt.Fprint(os.Stdout, FgCode(fg))
+	fmt.Fprint(os.Stdout, BgCode(bg))
 }
 
 func CursorVisible(visible bool) {
+	if PlainMode {
+		return
+	}
+
 	if visible {
 		fmt.Print("\033[?25h")
 	} else {
@@ -103,9 +173,27 @@ func CursorVisible(visible bool) {
 }
 
 func SetAlternateBuffer(on bool) {
+	if PlainMode {
+		return
+	}
+
 	if on {
 		fmt.Fprintf(os.Stdout, "\033[?1049h")
 	} else {
 		fmt.Fprintf(os.Stdout, "\033[?1049l")
 	}
 }
+
+// Turns on xterm mouse reporting of presses, releases and drags in the SGR
+// extended format so that coordinates are not limited to 223 columns.
+func SetMouseReporting(on bool) {
+	if PlainMode {
+		return
+	}
+
+	if on {
+		fmt.Fprint(os.Stdout, "\033[?1000h\033[?1002h\033[?1006h")
+	} else {
+		fmt.Fprint(os.Stdout, "\033[?1006l\033[?1002l\033[?1000l")
+	}
+}
@@ -2,8 +2,11 @@ package uci
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -11,18 +14,33 @@ const (
 	UCI_SEND_UCI          = "uci\n"
 	UCI_SEND_POSITION_FEN = "position fen %s\n"
 	UCI_SEND_GO_MOVETIME  = "go movetime %d\n"
+	UCI_SEND_GO_DEPTH     = "go depth %d movetime %d\n"
+	UCI_SEND_STOP         = "stop\n"
+	UCI_SEND_SETOPTION    = "setoption name %s value %s\n"
 
 	UCI_RECV_UCIOK    = "uciok"
 	UCI_RECV_BESTMOVE = "bestmove"
+	UCI_RECV_INFO     = "info"
+	UCI_RECV_OPTION   = "option name "
 )
 
+const DEFAULT_MOVETIME_MS = 10
+
+// Centipawns a mate is counted as
+const MATE_SCORE = 100000
+
 type Pipe struct {
-	in  *bufio.Writer
-	out *bufio.Scanner
+	in         *bufio.Writer
+	out        *bufio.Scanner
+	MoveTimeMs int // Time the engine may think about each move
+	Depth      int // Plies the engine searches at most, 0 for no limit
+	// Declarations of the options the engine offers by name, e.g.
+	// "type check default false" for UCI_Chess960. Filled in by Handshake.
+	Options map[string]string
 }
 
 func CreatePipe(cmd string) (Pipe, error) {
-	pipe := Pipe{}
+	pipe := Pipe{MoveTimeMs: DEFAULT_MOVETIME_MS}
 	uciProg := exec.Command(cmd)
 	var err error
 
@@ -48,6 +66,30 @@ func (p *Pipe) Send(cmd string) error {
 	return err
 }
 
+// Starts the UCI session and waits until the engine is ready, noting the
+// options it offers
+func (p *Pipe) Handshake() {
+	p.Options = map[string]string{}
+	p.Send(UCI_SEND_UCI)
+	for p.out.Scan() {
+		line := p.out.Text()
+		if strings.HasPrefix(line, UCI_RECV_UCIOK) {
+			return
+		}
+		// Option names may contain spaces, the type follows them
+		if decl, ok := strings.CutPrefix(line, UCI_RECV_OPTION); ok {
+			name, rest, _ := strings.Cut(decl, " type ")
+			p.Options[name] = "type " + rest
+		}
+	}
+}
+
+// Returns true if the engine offers UCI_Variant with the given variant
+func (p *Pipe) SupportsVariant(name string) bool {
+	decl, ok := p.Options["UCI_Variant"]
+	return ok && slices.Contains(strings.Fields(decl), name)
+}
+
 func (p *Pipe) WaitForExpected(prefix string) string {
 	// TODO: We need to handle the situation where this fails
 	fullLine := ""
@@ -61,6 +103,11 @@ func (p *Pipe) WaitForExpected(prefix string) string {
 	return fullLine
 }
 
+// Sets an engine option, such as UCI_Chess960. Call it before the first search.
+func (p *Pipe) SetOption(name string, value string) error {
+	return p.Send(fmt.Sprintf(UCI_SEND_SETOPTION, name, value))
+}
+
 func (p *Pipe) SendPositionFen(fen string) {
 	cmd := fmt.Sprintf(UCI_SEND_POSITION_FEN, fen)
 	p.Send(cmd)
@@ -75,6 +122,110 @@ func (p *Pipe) CalculateBestMove(movetime int) string {
 	cmd := fmt.Sprintf(UCI_SEND_GO_MOVETIME, movetime)
 	p.Send(cmd)
 	bestMoveLine := p.WaitForExpected(UCI_RECV_BESTMOVE)
+	// Example: bestmove e7e8q ponder e7e5
+	fields := strings.Fields(bestMoveLine)
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// Asks the engine for its move in the given position. If ctx is cancelled
+// while the engine is thinking, it is told to stop and ctx.Err() is returned.
+func (p *Pipe) BestMove(ctx context.Context, fen string) (string, error) {
+	lines, err := p.think(ctx, fen)
+	if err != nil {
+		return "", err
+	}
+
 	// Example: bestmove e2e4 ponder e7e5
-	return bestMoveLine[9:13]
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 2 || fields[0] != UCI_RECV_BESTMOVE {
+		return "", fmt.Errorf("Engine did not reply with a move")
+	}
+	return fields[1], nil
+}
+
+// Implements gamestate.DrawJudge. The engine searches the position as if to
+// move and accepts a draw unless the last score it reported is in its favour.
+func (p *Pipe) AcceptDraw(ctx context.Context, fen string) (bool, error) {
+	lines, err := p.think(ctx, fen)
+	if err != nil {
+		return false, err
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if score, ok := parseScore(lines[i]); ok {
+			return score <= 0, nil
+		}
+	}
+	// Without a score the engine can not tell, so it plays on
+	return false, nil
+}
+
+// Runs a search and returns the lines the engine wrote, ending with the
+// bestmove line
+func (p *Pipe) think(ctx context.Context, fen string) ([]string, error) {
+	p.SendPositionFen(fen)
+	if p.Depth > 0 {
+		p.Send(fmt.Sprintf(UCI_SEND_GO_DEPTH, p.Depth, p.MoveTimeMs))
+	} else {
+		p.SendGoMoveTime(p.MoveTimeMs)
+	}
+
+	reply := make(chan []string, 1)
+	go func() {
+		var lines []string
+		for p.out.Scan() {
+			lines = append(lines, p.out.Text())
+			if strings.HasPrefix(p.out.Text(), UCI_RECV_BESTMOVE) {
+				break
+			}
+		}
+		reply <- lines
+	}()
+
+	var lines []string
+	select {
+	case lines = <-reply:
+	case <-ctx.Done():
+		// The engine still answers after a stop, read it so the next search
+		// does not pick up this reply
+		p.Send(UCI_SEND_STOP)
+		<-reply
+		return nil, ctx.Err()
+	}
+	// An engine that exits mid-search leaves off with an info line
+	if len(lines) == 0 || !strings.HasPrefix(lines[len(lines)-1], UCI_RECV_BESTMOVE) {
+		return nil, fmt.Errorf("Engine did not reply with a move")
+	}
+	return lines, nil
+}
+
+// Reads the score of an info line in centipawns from the point of view of the
+// engine. Mates count as a large score.
+// Example: info depth 12 score cp -35 nodes 10240 pv e7e5
+func parseScore(line string) (int, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != UCI_RECV_INFO {
+		return 0, false
+	}
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i] != "score" {
+			continue
+		}
+		value, err := strconv.Atoi(fields[i+2])
+		if err != nil {
+			return 0, false
+		}
+		switch fields[i+1] {
+		case "cp":
+			return value, true
+		case "mate":
+			if value < 0 {
+				return -MATE_SCORE, true
+			}
+			return MATE_SCORE, true
+		}
+	}
+	return 0, false
 }
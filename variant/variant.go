@@ -0,0 +1,226 @@
+package variant
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jesselli/tchess/board"
+	"github.com/Jesselli/tchess/fen"
+	"github.com/Jesselli/tchess/piece"
+)
+
+// Variants by the name given to -variant, which is also their UCI_Variant.
+// Standard chess has no Variant.
+var Variants = map[string]board.Variant{
+	"kingofthehill": KingOfTheHill{},
+	"3check":        ThreeCheck{},
+	"horde":         Horde{},
+	"antichess":     Antichess{},
+	"crazyhouse":    Crazyhouse{},
+}
+
+var Names = []string{"standard", "kingofthehill", "3check", "horde", "antichess", "crazyhouse"}
+
+// Returns the variant with the given name, nil for standard chess
+func ByName(name string) (board.Variant, error) {
+	if name == "" || name == "standard" {
+		return nil, nil
+	}
+	v, ok := Variants[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown variant '%s'. Use one of %s", name, strings.Join(Names, ", "))
+	}
+	return v, nil
+}
+
+func colorName(c piece.Color) string {
+	if c == piece.WHITE {
+		return "white"
+	}
+	return "black"
+}
+
+// Squares d5, e5, d4 and e4
+var hill = []int{27, 28, 35, 36}
+
+// KingOfTheHill is also won by bringing the king to one of the four center
+// squares
+type KingOfTheHill struct {
+	board.Standard
+}
+
+func (KingOfTheHill) Name() string {
+	return "kingofthehill"
+}
+
+func (KingOfTheHill) Winner(b board.Board, color piece.Color) (piece.Color, string) {
+	for _, sq := range hill {
+		if p := b.Pieces[sq]; p.Type == piece.KING {
+			return p.Color, fmt.Sprintf("The %s king reached the hill.", colorName(p.Color))
+		}
+	}
+	return 0, ""
+}
+
+// Kings can reach the hill, so no position is dead
+func (KingOfTheHill) Mating(b board.Board, color piece.Color) board.Mating {
+	return board.MATING_FORCED
+}
+
+// THREE_CHECKS given win a game of Three-check
+const THREE_CHECKS = 3
+
+// ThreeCheck is also won by checking the opposing king three times
+type ThreeCheck struct {
+	board.Standard
+}
+
+func (ThreeCheck) Name() string {
+	return "3check"
+}
+
+func (ThreeCheck) Winner(b board.Board, color piece.Color) (piece.Color, string) {
+	for _, c := range []piece.Color{piece.WHITE, piece.BLACK} {
+		if b.Checks[c] >= THREE_CHECKS {
+			return c, fmt.Sprintf("The %s king was checked three times.", colorName(c.Opposite()))
+		}
+	}
+	return 0, ""
+}
+
+// Any piece besides the king can give check
+func (ThreeCheck) Mating(b board.Board, color piece.Color) board.Mating {
+	for _, p := range b.Pieces {
+		if p.Color == color && p.Type != piece.KING {
+			return board.MATING_FORCED
+		}
+	}
+	return board.MATING_NONE
+}
+
+// Horde pits the pieces of black against 36 white pawns without a king. Black
+// wins by taking all of them, white by checkmate.
+type Horde struct {
+	board.Standard
+}
+
+func (Horde) Name() string {
+	return "horde"
+}
+
+// White has no king, and more pawns than standard chess allows, some of them
+// on the first rank
+func (Horde) FenChecks() fen.Check {
+	return 0
+}
+
+func (Horde) StartFen() string {
+	return "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1"
+}
+
+func (Horde) Winner(b board.Board, color piece.Color) (piece.Color, string) {
+	for _, p := range b.Pieces {
+		if p.Color == piece.WHITE {
+			return 0, ""
+		}
+	}
+	return piece.BLACK, "The horde was destroyed."
+}
+
+// Black can always take the rest of the horde
+func (Horde) Mating(b board.Board, color piece.Color) board.Mating {
+	if color == piece.BLACK {
+		return board.MATING_FORCED
+	}
+	return b.Mating(color)
+}
+
+// Antichess is won by losing all pieces or by being stalemated. Captures are
+// compulsory and the king is an ordinary piece that pawns may promote to.
+type Antichess struct {
+	board.Standard
+}
+
+func (Antichess) Name() string {
+	return "antichess"
+}
+
+func (Antichess) StartFen() string {
+	return strings.Replace(fen.DEFAULT, "KQkq", "-", 1)
+}
+
+// Kings are ordinary pieces, so a player may have any number of them
+func (Antichess) FenChecks() fen.Check {
+	return fen.CHECK_ALL &^ fen.CHECK_KINGS
+}
+
+func (Antichess) RoyalKings() bool {
+	return false
+}
+
+func (Antichess) Promotions() []piece.Type {
+	return []piece.Type{piece.QUEEN, piece.ROOK, piece.BISHOP, piece.KNIGHT, piece.KING}
+}
+
+func (Antichess) AllowMove(b board.Board, mv board.Move, color piece.Color) (bool, string) {
+	if mv.Capture {
+		return true, ""
+	}
+	for _, other := range b.AllMoves(color) {
+		if !other.Capture {
+			continue
+		}
+		if ok, _ := b.ValidateMove(other, color); ok {
+			return false, fmt.Sprintf("You have to capture while you can, e.g. %s", other.ToLongAlgebraic())
+		}
+	}
+	return true, ""
+}
+
+func (Antichess) Winner(b board.Board, color piece.Color) (piece.Color, string) {
+	if len(b.AllValidMoves(color)) == 0 {
+		return color, fmt.Sprintf("The %s player has no moves left.", colorName(color))
+	}
+	return 0, ""
+}
+
+// Either player can always lose their pieces
+func (Antichess) Mating(b board.Board, color piece.Color) board.Mating {
+	return board.MATING_FORCED
+}
+
+// Crazyhouse puts captured pieces into the pocket of the capturer, who may drop
+// them on an empty square instead of moving
+type Crazyhouse struct {
+	board.Standard
+}
+
+func (Crazyhouse) Name() string {
+	return "crazyhouse"
+}
+
+func (Crazyhouse) StartFen() string {
+	return strings.Replace(fen.DEFAULT, " ", "[] ", 1)
+}
+
+// Dropped pieces come on top of the ones a player started with
+func (Crazyhouse) FenChecks() fen.Check {
+	return fen.CHECK_ALL &^ fen.CHECK_MATERIAL
+}
+
+func (Crazyhouse) Drops() bool {
+	return true
+}
+
+// Taken pieces come back, so only bare kings with empty pockets are dead
+func (Crazyhouse) Mating(b board.Board, color piece.Color) board.Mating {
+	for _, p := range b.Pieces {
+		if p.Type != piece.NONE && p.Type != piece.KING {
+			return board.MATING_FORCED
+		}
+	}
+	if b.Pockets != ([3][piece.KING + 1]int{}) {
+		return board.MATING_FORCED
+	}
+	return board.MATING_NONE
+}